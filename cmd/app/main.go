@@ -9,9 +9,14 @@ import (
 	"syscall"
 	"time"
 	"user-service/internal/config"
+	"user-service/internal/cryptobox"
 	"user-service/internal/db"
 	handler "user-service/internal/handlers"
+	"user-service/internal/mailer"
 	"user-service/internal/middleware"
+	"user-service/internal/password"
+	"user-service/internal/proofstore"
+	"user-service/internal/rbac"
 	"user-service/internal/repository"
 	"user-service/internal/services"
 
@@ -38,6 +43,11 @@ import (
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key created with POST /api/api-keys.
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -55,35 +65,213 @@ func main() {
 
 	userRepo := repository.NewPostgresUserRepository(dbConn)
 	taskRepo := repository.NewTaskRepository(dbConn)
+	sessionRepo := repository.NewPostgresSessionRepository(dbConn)
+	refreshTokenRepo := repository.NewPostgresRefreshTokenRepository(dbConn)
+	revocationRepo := repository.NewPostgresRevocationRepository(dbConn)
+	passwordResetRepo := repository.NewPostgresPasswordResetRepository(dbConn)
+	loginAttemptRepo := repository.NewPostgresLoginAttemptRepository(dbConn)
+	apiKeyRepo := repository.NewPostgresAPIKeyRepository(dbConn)
+	identityRepo := repository.NewPostgresIdentityRepository(dbConn)
+	twoFactorRepo := repository.NewPostgresTwoFactorRepository(dbConn)
+	emailVerificationRepo := repository.NewPostgresEmailVerificationRepository(dbConn)
+	impersonationRepo := repository.NewPostgresImpersonationRepository(dbConn)
+	balanceRepo := repository.NewPostgresBalanceRepository(dbConn)
+	guestRepo := repository.NewPostgresGuestRepository(dbConn)
+	magicLinkRepo := repository.NewPostgresMagicLinkRepository(dbConn)
+	webauthnRepo := repository.NewPostgresWebAuthnRepository(dbConn)
 
-	jwtServices := services.NewJWTService(cfg.JWT.Secret, int(cfg.JWT.AccessTokenDuration), int(cfg.JWT.RefreshTokenDuration))
-	authServices := services.NewAuthService(userRepo, jwtServices)
-	userService := services.NewUserService(userRepo, taskRepo)
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Mailer init error: %v", err)
+	}
 
-	authHandler := handler.NewAuthHandler(authServices)
-	userHandler := handler.NewUserHandler(userService)
-	authMw := middleware.NewAuthMiddleware(jwtServices)
+	proofs, err := proofstore.NewLocalStore(cfg.TaskProof.Dir)
+	if err != nil {
+		log.Fatalf("Task proof store init error: %v", err)
+	}
 
-	router := gin.Default()
+	var jwtKeys *services.KeySet
+	if len(cfg.JWT.Keys) == 0 {
+		log.Println("JWT_KEYS is not set, signing tokens with an ephemeral key")
+		jwtKeys, err = services.NewEphemeralKeySet()
+	} else {
+		jwtKeys, err = services.LoadKeySet(cfg.JWT)
+	}
+	if err != nil {
+		log.Fatalf("JWT keys load error: %v", err)
+	}
 
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	router.Use(gin.Logger())
+	jwtServices := services.NewJWTService(cfg.JWT, jwtKeys)
+	revocationService := services.NewRevocationService(services.NewCachedRevocationStore(revocationRepo, cfg.JWT.RevocationCacheTTL))
+	var secretBox *cryptobox.Box
+	if len(cfg.TwoFactor.EncryptionKey) == 0 {
+		log.Println("WARNING: TWO_FACTOR_ENCRYPTION_KEY is not set, encrypting 2FA secrets with an ephemeral key; authenticators enrolled now stop working after a restart")
+		secretBox, err = cryptobox.NewEphemeral()
+	} else {
+		secretBox, err = cryptobox.New(cfg.TwoFactor.EncryptionKey)
+	}
+	if err != nil {
+		log.Fatalf("Secret encryption init error: %v", err)
+	}
+
+	var breachedPasswords password.BreachChecker
+	if cfg.Password.BreachedListPath != "" {
+		breachedPasswords, err = password.NewBreachChecker(cfg.Password.BreachedListPath)
+		if err != nil {
+			log.Fatalf("Breached password list error: %v", err)
+		}
+	}
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password, breachedPasswords)
+	if err != nil {
+		log.Fatalf("Password policy error: %v", err)
+	}
+
+	passwordHasher, err := password.NewArgon2idHasher(cfg.PasswordHash)
+	if err != nil {
+		log.Fatalf("Password hasher error: %v", err)
+	}
+
+	emailService, err := services.NewEmailVerificationService(cfg.Email, userRepo, emailVerificationRepo, mail)
+	if err != nil {
+		log.Fatalf("Email verification init error: %v", err)
+	}
+
+	loginThrottler := services.NewLoginThrottler(cfg.Login, loginAttemptRepo)
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo, secretBox, cfg.TwoFactor.Issuer)
+	authServices := services.NewAuthService(
+		userRepo, sessionRepo, refreshTokenRepo, jwtServices, revocationService,
+		loginThrottler, twoFactorService, emailService, passwordPolicy, passwordHasher, cfg.TwoFactor.ChallengeTTL,
+	)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	adminService := services.NewAdminService(cfg.Impersonation, userRepo, impersonationRepo, jwtServices, authServices, apiKeyService, revocationService, loginThrottler, passwordPolicy, passwordHasher)
+	if err := adminService.BootstrapAdmin(context.Background(), cfg.Admin); err != nil {
+		log.Fatalf("Admin bootstrap error: %v", err)
+	}
+
+	oidcService := services.NewOIDCService(cfg.OIDC, userRepo, identityRepo, authServices)
+	userService := services.NewUserService(cfg.TaskProof, userRepo, taskRepo, emailService, proofs)
+	taskService := services.NewTaskService(taskRepo, proofs)
+	sessionService := services.NewSessionService(sessionRepo, revocationService)
+	magicLinkService := services.NewMagicLinkService(cfg.MagicLink, userRepo, magicLinkRepo, jwtServices, authServices, mail)
+	passkeyService := services.NewPasskeyService(cfg.WebAuthn, webauthnRepo, userRepo, authServices)
+	guestService := services.NewGuestService(cfg.Guest, guestRepo, userRepo, authServices, emailService, passwordPolicy, passwordHasher)
+	accountService := services.NewAccountService(
+		cfg.Account, userRepo, taskRepo, balanceRepo, sessionRepo, identityRepo,
+		authServices, apiKeyService, twoFactorService, passwordHasher, proofs,
+	)
+	passwordResetService := services.NewPasswordResetService(cfg.PasswordReset, userRepo, passwordResetRepo, authServices, apiKeyService, passwordPolicy, passwordHasher, mail)
+
+	cookies := handler.NewCookies(cfg.Cookie)
+	authHandler := handler.NewAuthHandler(authServices, cookies)
+	userHandler := handler.NewUserHandler(userService, cfg.TaskProof)
+	taskHandler := handler.NewTaskHandler(taskService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	jwksHandler := handler.NewJWKSHandler(jwtServices)
+	passwordResetHandler := handler.NewPasswordResetHandler(passwordResetService)
+	emailHandler := handler.NewEmailHandler(emailService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService)
+	oidcHandler := handler.NewOIDCHandler(oidcService, cookies)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	accountHandler := handler.NewAccountHandler(accountService, cookies)
+	guestHandler := handler.NewGuestHandler(guestService, cookies)
+	magicLinkHandler := handler.NewMagicLinkHandler(magicLinkService, cookies)
+	passkeyHandler := handler.NewPasskeyHandler(passkeyService, cookies)
+	authMw := middleware.NewAuthMiddleware(jwtServices, revocationService, apiKeyService, cfg.Cookie.TrustedOrigins)
+
+	router := gin.New()
+
+	router.Use(gin.LoggerWithFormatter(middleware.RequestLogFormatter))
 	router.Use(gin.Recovery())
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// ---- PUBLIC ROUTERS ----
 	router.POST("/register", authHandler.Register)
 	router.POST("/login", authHandler.Login)
+	router.POST("/login/2fa", authHandler.LoginTwoFactor)
+	router.POST("/login/magic-link", magicLinkHandler.RequestMagicLink)
+	router.POST("/login/magic-link/verify", magicLinkHandler.LoginMagicLink)
+	router.POST("/login/passkey/begin", passkeyHandler.BeginLogin)
+	router.POST("/login/passkey/finish", passkeyHandler.FinishLogin)
+	router.POST("/refresh", authHandler.Refresh)
+	router.POST("/guest", guestHandler.CreateGuest)
+	router.POST("/guest/login", guestHandler.LoginGuest)
+	router.POST("/password/forgot", passwordResetHandler.ForgotPassword)
+	router.POST("/password/reset", passwordResetHandler.ResetPassword)
+	router.POST("/verify-email", emailHandler.VerifyEmail)
+	router.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+	router.GET("/oidc/providers", oidcHandler.Providers)
+	router.GET("/oidc/:provider/login", oidcHandler.Login)
+	router.GET("/oidc/:provider/callback", oidcHandler.Callback)
 
 	api := router.Group("/api")
-	api.Use(authMw.JWT())
+	api.Use(authMw.Authenticate())
 	{
-		api.POST("/logout", authHandler.Logout)
+		// Account management is available only to signed in users, not to
+		// API keys.
+		account := api.Group("")
+		account.Use(authMw.RequireSession())
+		{
+			account.POST("/logout", authHandler.Logout)
+			account.POST("/logout/all", authHandler.LogoutAll)
+			account.POST("/password/change", authHandler.ChangePassword)
+			account.PUT("/email", emailHandler.ChangeEmail)
+			account.GET("/sessions", sessionHandler.ListSessions)
+			account.DELETE("/sessions/:id", sessionHandler.RevokeSession)
+			account.POST("/2fa/enroll", twoFactorHandler.Enroll)
+			account.POST("/2fa/confirm", twoFactorHandler.Confirm)
+			account.POST("/2fa/disable", twoFactorHandler.Disable)
+			account.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+			account.POST("/passkeys/register/begin", passkeyHandler.BeginRegistration)
+			account.POST("/passkeys/register/finish", passkeyHandler.FinishRegistration)
+			account.GET("/passkeys", passkeyHandler.ListPasskeys)
+			account.DELETE("/passkeys/:id", passkeyHandler.DeletePasskey)
+			account.POST("/oidc/:provider/link", oidcHandler.Link)
+			account.GET("/identities", oidcHandler.ListIdentities)
+			account.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+			account.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+			account.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
+			account.GET("/users/me/export", accountHandler.ExportData)
+			account.DELETE("/users/me", accountHandler.DeleteAccount)
+			account.POST("/users/me/restore", accountHandler.RestoreAccount)
+			account.POST("/guest/upgrade", guestHandler.UpgradeGuest)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(authMw.RequireSession(), authMw.RequireRole(rbac.RoleModerator, rbac.RoleAdmin))
+		{
+			admin.GET("/lockouts", adminHandler.ListLockouts)
+			admin.DELETE("/lockouts/:key", adminHandler.Unlock)
+			admin.POST("/users/:id/ban", adminHandler.BanUser)
+			admin.DELETE("/users/:id/ban", adminHandler.UnbanUser)
+			admin.PUT("/users/:id/role", adminHandler.ChangeRole)
+			admin.POST("/users/:id/impersonate", adminHandler.Impersonate)
+			admin.GET("/impersonations", adminHandler.ListImpersonations)
+			admin.DELETE("/impersonations/:id", adminHandler.EndImpersonation)
+			admin.GET("/tasks", taskHandler.AdminListTasks)
+			admin.POST("/tasks", taskHandler.CreateTask)
+			admin.PATCH("/tasks/:id", taskHandler.UpdateTask)
+			admin.POST("/tasks/:id/archive", taskHandler.ArchiveTask)
+			admin.GET("/task-submissions", taskHandler.ListSubmissions)
+			admin.GET("/task-submissions/:id/proof", taskHandler.DownloadProof)
+			admin.POST("/task-submissions/:id/approve", taskHandler.ApproveSubmission)
+			admin.POST("/task-submissions/:id/reject", taskHandler.RejectSubmission)
+		}
+
 		api.GET("/users/:id/status", userHandler.GetStatus)
 		api.GET("/users/leaderboard", userHandler.GetLeaderBoard)
+		api.GET("/users/me/tasks", userHandler.GetCompletedTasks)
+		api.GET("/tasks", taskHandler.ListTasks)
 		api.POST("/users/:id/task/complete", userHandler.CompleteTask)
 		api.POST("/users/:id/referrer", userHandler.AddReferrer)
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go accountService.RunPurger(jobsCtx, cfg.Account.PurgeInterval)
+	go guestService.RunCleanup(jobsCtx, cfg.Guest.CleanupInterval)
+
 	srv := &http.Server{
 		Addr:         cfg.Server.Address,
 		Handler:      router,
@@ -106,6 +294,8 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
+	stopJobs()
+
 	log.Println("⏸️  Stopping HTTP server...")
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("⚠️  HTTP server shutdown error: %v", err)
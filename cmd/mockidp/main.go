@@ -0,0 +1,30 @@
+// Command mockidp runs the oidctest provider so the OpenID Connect login can
+// be tried locally without a real identity provider. Every authorization
+// request is approved; add login_hint=<name> to the authorization URL to sign
+// in as <name>@example.com.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"user-service/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL as seen by the browser and the service")
+	clientID := flag.String("client-id", "user-service", "accepted client id")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret")
+	flag.Parse()
+
+	provider, err := oidctest.NewProvider(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("Mock IdP init error: %v", err)
+	}
+
+	log.Printf("Mock IdP listening on %s, discovery URL %s/.well-known/openid-configuration", *addr, *issuer)
+	if err := http.ListenAndServe(*addr, provider.Handler()); err != nil {
+		log.Fatalf("Mock IdP error: %v", err)
+	}
+}
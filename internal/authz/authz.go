@@ -0,0 +1,193 @@
+// Package authz decides whether the caller may perform an action on a
+// resource. Every protected action is declared once in Policies; handlers only
+// call Authorize.
+package authz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"user-service/internal/rbac"
+)
+
+var (
+	ErrUnauthenticated = errors.New("not authorized")
+	ErrForbidden       = errors.New("access denied")
+)
+
+type Action string
+
+const (
+	ActionViewUserStatus  Action = "users.status.view"
+	ActionViewLeaderboard Action = "users.leaderboard.view"
+	ActionCompleteTask    Action = "users.tasks.complete"
+	ActionViewTasks       Action = "tasks.view"
+	ActionViewTaskHistory Action = "users.tasks.history"
+	ActionSetReferrer     Action = "users.referrer.set"
+	ActionManageLockouts  Action = "admin.lockouts.manage"
+	ActionBanUser         Action = "admin.users.ban"
+	ActionChangeRole      Action = "admin.users.change_role"
+	ActionImpersonate     Action = "admin.users.impersonate"
+	ActionManageTasks     Action = "admin.tasks.manage"
+	ActionModerateTasks   Action = "admin.tasks.moderate"
+)
+
+// Subject is the authenticated caller. Scopes is nil for signed in users and
+// holds the granted scopes for API keys. ActorID is set when a staff member
+// acts as UserID through an impersonation token.
+type Subject struct {
+	UserID  int
+	Role    string
+	Scopes  []string
+	ActorID int
+}
+
+func (s Subject) IsAPIKey() bool {
+	return s.Scopes != nil
+}
+
+func (s Subject) IsImpersonated() bool {
+	return s.ActorID != 0
+}
+
+// Resource is what the action is performed on. OwnerID is the user the
+// resource belongs to, 0 for resources that belong to nobody.
+type Resource struct {
+	OwnerID int
+}
+
+// User is the resource of actions performed on a user account.
+func User(userID int) Resource {
+	return Resource{OwnerID: userID}
+}
+
+// None is the resource of actions that are not tied to any user.
+func None() Resource {
+	return Resource{}
+}
+
+// Rule grants access on its own; a policy allows the action when any of its
+// rules does.
+type Rule func(subject Subject, resource Resource) bool
+
+// Self allows acting on one's own resources.
+func Self() Rule {
+	return func(subject Subject, resource Resource) bool {
+		return resource.OwnerID != 0 && resource.OwnerID == subject.UserID
+	}
+}
+
+// Anyone allows every authenticated caller.
+func Anyone() Rule {
+	return func(Subject, Resource) bool {
+		return true
+	}
+}
+
+// HasPermission allows callers whose role grants the permission. API keys
+// carry no role and never match, and neither do impersonation tokens, so
+// acting as a moderator does not hand out the moderator's powers.
+func HasPermission(permission rbac.Permission) Rule {
+	return func(subject Subject, _ Resource) bool {
+		return !subject.IsAPIKey() && !subject.IsImpersonated() && rbac.HasPermission(subject.Role, permission)
+	}
+}
+
+// Policy declares who may perform an action. APIKeyScope is the scope an API
+// key needs on top of the rules; an empty scope keeps API keys out entirely.
+type Policy struct {
+	Rules       []Rule
+	APIKeyScope string
+}
+
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+)
+
+// Policies is the single list of authorization rules of the API.
+var Policies = map[Action]Policy{
+	ActionViewUserStatus: {
+		Rules:       []Rule{Self(), HasPermission(rbac.PermViewAnyUser)},
+		APIKeyScope: ScopeUsersRead,
+	},
+	ActionViewLeaderboard: {
+		Rules:       []Rule{Anyone()},
+		APIKeyScope: ScopeUsersRead,
+	},
+	ActionViewTasks: {
+		Rules:       []Rule{Anyone()},
+		APIKeyScope: ScopeUsersRead,
+	},
+	ActionViewTaskHistory: {
+		Rules:       []Rule{Self(), HasPermission(rbac.PermViewAnyUser)},
+		APIKeyScope: ScopeUsersRead,
+	},
+	ActionCompleteTask: {
+		Rules:       []Rule{Self()},
+		APIKeyScope: ScopeUsersWrite,
+	},
+	ActionSetReferrer: {
+		Rules:       []Rule{Self()},
+		APIKeyScope: ScopeUsersWrite,
+	},
+	ActionManageLockouts: {
+		Rules: []Rule{HasPermission(rbac.PermManageLockouts)},
+	},
+	ActionBanUser: {
+		Rules: []Rule{HasPermission(rbac.PermBanUsers)},
+	},
+	ActionChangeRole: {
+		Rules: []Rule{HasPermission(rbac.PermManageRoles)},
+	},
+	ActionImpersonate: {
+		Rules: []Rule{HasPermission(rbac.PermImpersonate)},
+	},
+	ActionManageTasks: {
+		Rules: []Rule{HasPermission(rbac.PermManageTasks)},
+	},
+	ActionModerateTasks: {
+		Rules: []Rule{HasPermission(rbac.PermModerateTasks)},
+	},
+}
+
+type subjectKey struct{}
+
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(Subject)
+	return subject, ok
+}
+
+// Authorize checks the caller stored in ctx against the policy of the action.
+// Actions without a policy are denied.
+func Authorize(ctx context.Context, action Action, resource Resource) error {
+	subject, ok := SubjectFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	return Check(subject, action, resource)
+}
+
+func Check(subject Subject, action Action, resource Resource) error {
+	policy, ok := Policies[action]
+	if !ok {
+		return ErrForbidden
+	}
+
+	if subject.IsAPIKey() && (policy.APIKeyScope == "" || !slices.Contains(subject.Scopes, policy.APIKeyScope)) {
+		return ErrForbidden
+	}
+
+	for _, rule := range policy.Rules {
+		if rule(subject, resource) {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
@@ -0,0 +1,112 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"user-service/internal/rbac"
+)
+
+// The resource under test belongs to user 1. User 2 is another regular user,
+// 3 a moderator, 4 an admin.
+const ownerID = 1
+
+var allScopes = []string{ScopeUsersRead, ScopeUsersWrite}
+
+type subjectCase struct {
+	name    string
+	subject func(policy Policy) Subject
+}
+
+var subjects = []subjectCase{
+	{"self", func(Policy) Subject { return Subject{UserID: ownerID, Role: rbac.RoleUser} }},
+	{"other user", func(Policy) Subject { return Subject{UserID: 2, Role: rbac.RoleUser} }},
+	{"moderator", func(Policy) Subject { return Subject{UserID: 3, Role: rbac.RoleModerator} }},
+	{"admin", func(Policy) Subject { return Subject{UserID: 4, Role: rbac.RoleAdmin} }},
+	{"api key with scope", func(policy Policy) Subject {
+		scopes := allScopes
+		if policy.APIKeyScope != "" {
+			scopes = []string{policy.APIKeyScope}
+		}
+		return Subject{UserID: ownerID, Scopes: scopes}
+	}},
+	{"api key without scope", func(policy Policy) Subject {
+		scopes := slices.DeleteFunc(slices.Clone(allScopes), func(scope string) bool {
+			return scope == policy.APIKeyScope
+		})
+		if policy.APIKeyScope == "" {
+			scopes = []string{}
+		}
+		return Subject{UserID: ownerID, Scopes: scopes}
+	}},
+	{"admin acting as self", func(Policy) Subject { return Subject{UserID: ownerID, Role: rbac.RoleUser, ActorID: 4} }},
+	{"admin acting as moderator", func(Policy) Subject { return Subject{UserID: 3, Role: rbac.RoleModerator, ActorID: 4} }},
+}
+
+func TestPolicies(t *testing.T) {
+	// allowed lists, per action, the subjects above that may perform it.
+	tests := []struct {
+		action   Action
+		resource Resource
+		allowed  []string
+	}{
+		{ActionViewUserStatus, User(ownerID), []string{"self", "moderator", "admin", "api key with scope", "admin acting as self"}},
+		{ActionViewLeaderboard, None(), []string{"self", "other user", "moderator", "admin", "api key with scope", "admin acting as self", "admin acting as moderator"}},
+		{ActionViewTasks, None(), []string{"self", "other user", "moderator", "admin", "api key with scope", "admin acting as self", "admin acting as moderator"}},
+		{ActionViewTaskHistory, User(ownerID), []string{"self", "moderator", "admin", "api key with scope", "admin acting as self"}},
+		{ActionCompleteTask, User(ownerID), []string{"self", "api key with scope", "admin acting as self"}},
+		{ActionSetReferrer, User(ownerID), []string{"self", "api key with scope", "admin acting as self"}},
+		{ActionManageLockouts, None(), []string{"moderator", "admin"}},
+		{ActionBanUser, User(ownerID), []string{"moderator", "admin"}},
+		{ActionChangeRole, User(ownerID), []string{"admin"}},
+		{ActionImpersonate, User(ownerID), []string{"admin"}},
+		{ActionManageTasks, None(), []string{"admin"}},
+		{ActionModerateTasks, None(), []string{"moderator", "admin"}},
+	}
+
+	covered := make(map[Action]bool, len(tests))
+	for _, tt := range tests {
+		covered[tt.action] = true
+		policy := Policies[tt.action]
+
+		for _, sc := range subjects {
+			t.Run(string(tt.action)+"/"+sc.name, func(t *testing.T) {
+				err := Check(sc.subject(policy), tt.action, tt.resource)
+
+				if slices.Contains(tt.allowed, sc.name) {
+					if err != nil {
+						t.Fatalf("denied: %v", err)
+					}
+				} else if !errors.Is(err, ErrForbidden) {
+					t.Fatalf("got %v, want ErrForbidden", err)
+				}
+			})
+		}
+	}
+
+	for action := range Policies {
+		if !covered[action] {
+			t.Errorf("policy of %s is not tested", action)
+		}
+	}
+}
+
+func TestCheckDeniesUnknownAction(t *testing.T) {
+	admin := Subject{UserID: 4, Role: rbac.RoleAdmin}
+
+	if err := Check(admin, Action("unknown"), None()); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("got %v, want ErrForbidden", err)
+	}
+}
+
+func TestAuthorizeWithoutSubject(t *testing.T) {
+	if err := Authorize(context.Background(), ActionViewLeaderboard, None()); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("got %v, want ErrUnauthenticated", err)
+	}
+
+	ctx := WithSubject(context.Background(), Subject{UserID: ownerID, Role: rbac.RoleUser})
+	if err := Authorize(ctx, ActionViewLeaderboard, None()); err != nil {
+		t.Fatalf("got %v, want access", err)
+	}
+}
@@ -1,22 +1,60 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server        ServerConfig
+	Cookie        CookieConfig
+	Database      DatabaseConfig
+	JWT           JWTConfig
+	Mail          MailConfig
+	PasswordReset PasswordResetConfig
+	Email         EmailConfig
+	Login         LoginProtectionConfig
+	TwoFactor     TwoFactorConfig
+	Password      PasswordPolicyConfig
+	PasswordHash  PasswordHashConfig
+	OIDC          OIDCConfig
+	Admin         AdminBootstrapConfig
+	Impersonation ImpersonationConfig
+	Account       AccountConfig
+	Guest         GuestConfig
+	MagicLink     MagicLinkConfig
+	WebAuthn      WebAuthnConfig
+	TaskProof     TaskProofConfig
 }
 
+// ServerConfig.Environment is "development" on a developer machine, where
+// missing secrets are replaced with ephemeral ones, and anything else in
+// production.
 type ServerConfig struct {
-	Address  string
-	LogLevel string
+	Address     string
+	LogLevel    string
+	Environment string
+}
+
+func (c ServerConfig) Development() bool {
+	return c.Environment == "development"
+}
+
+// CookieConfig sets the attributes of the cookies the service issues.
+// SameSite is one of lax, strict or none; none requires Secure. Requests
+// authenticated by cookie from TrustedOrigins, besides the service's own
+// host, pass the CSRF origin check.
+type CookieConfig struct {
+	Secure         bool
+	Domain         string
+	SameSite       string
+	TrustedOrigins []string
 }
 
 type DatabaseConfig struct {
@@ -25,9 +63,160 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret               string
+	Keys                 []JWTKeyConfig
+	SigningKeyID         string
+	Issuer               string
+	Audience             string
+	Leeway               time.Duration
 	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
+	RevocationCacheTTL   time.Duration
+}
+
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	LogPath      string
+}
+
+type PasswordResetConfig struct {
+	URL      string
+	TokenTTL time.Duration
+}
+
+// MagicLinkConfig controls passwordless sign-in links. A link is valid for
+// TokenTTL; within RateWindow at most MaxPerEmail links are sent to one
+// address and MaxPerIP are requested from one client IP.
+type MagicLinkConfig struct {
+	URL         string
+	TokenTTL    time.Duration
+	MaxPerEmail int
+	MaxPerIP    int
+	RateWindow  time.Duration
+}
+
+// WebAuthnConfig identifies the service to passkey authenticators. RPID is
+// the domain passkeys are bound to, Origins the exact origins of the pages
+// that run the ceremonies, and Timeout how long a ceremony may take.
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	Timeout time.Duration
+}
+
+// TaskProofConfig controls the files users attach as proof of completed
+// tasks. Files are kept in Dir and may be at most MaxBytes long.
+type TaskProofConfig struct {
+	Dir      string
+	MaxBytes int64
+}
+
+// EmailConfig controls the email addresses of users. With Required every
+// registration needs an address. Until it is verified the user is limited by
+// UnverifiedRestrictions.
+type EmailConfig struct {
+	Required               bool
+	VerificationURL        string
+	VerificationTokenTTL   time.Duration
+	UnverifiedRestrictions []string
+}
+
+// LoginProtectionConfig controls throttling of failed logins. After
+// FreeAttempts failures for a username every further attempt is delayed
+// exponentially starting from BackoffBase, and at MaxAttempts the username is
+// locked for LockoutDuration. Client IPs are only locked, at IPMaxAttempts.
+type LoginProtectionConfig struct {
+	FreeAttempts    int
+	MaxAttempts     int
+	IPMaxAttempts   int
+	BackoffBase     time.Duration
+	LockoutDuration time.Duration
+	AttemptWindow   time.Duration
+}
+
+// TwoFactorConfig.EncryptionKey protects the TOTP secrets at rest. It may
+// only be left empty in development.
+type TwoFactorConfig struct {
+	EncryptionKey []byte
+	Issuer        string
+	ChallengeTTL  time.Duration
+}
+
+type PasswordPolicyConfig struct {
+	MinLength        int
+	MaxBytes         int
+	RequiredClasses  []string
+	BreachedListPath string
+}
+
+// PasswordHashConfig holds the Argon2id cost: memory in KiB, number of passes
+// and lanes. Raising any of them rehashes passwords on the next login.
+type PasswordHashConfig struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+	StateTTL  time.Duration
+}
+
+// OIDCProviderConfig describes one external identity provider. TrustEmail
+// lets a first login link to the local account that verified the same
+// address, which is only safe for providers that own the addresses they
+// vouch for, such as a corporate IdP.
+type OIDCProviderConfig struct {
+	Name         string
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+	TrustEmail   bool
+}
+
+// ImpersonationConfig limits how long an impersonation token is valid.
+type ImpersonationConfig struct {
+	TokenTTL time.Duration
+}
+
+// AccountConfig controls account deletion. A deleted account keeps its data
+// and can be restored until DeletionGracePeriod has passed, then it is purged
+// for good; the purge runs every PurgeInterval.
+type AccountConfig struct {
+	DeletionGracePeriod time.Duration
+	PurgeInterval       time.Duration
+}
+
+// GuestConfig controls guest accounts. Guests inactive for longer than
+// Retention are deleted by a job running every CleanupInterval. Within
+// RateWindow at most MaxPerIP guests are created from one client IP.
+type GuestConfig struct {
+	Retention       time.Duration
+	CleanupInterval time.Duration
+	MaxPerIP        int
+	RateWindow      time.Duration
+}
+
+// AdminBootstrapConfig names the account that becomes the first admin on
+// startup while no admin exists. The user is created with Password when it
+// does not exist yet.
+type AdminBootstrapConfig struct {
+	Username string
+	Password string
+}
+
+// JWTKeyConfig points at a PEM file holding an RSA or Ed25519 key. Only the
+// signing key needs the private part, retired keys may be public keys only.
+type JWTKeyConfig struct {
+	ID   string
+	Path string
 }
 
 func LoadConfig() (*Config, error) {
@@ -47,20 +236,118 @@ func LoadConfig() (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Address:  viper.GetString("SERVER_ADDRESS"),
-			LogLevel: viper.GetString("LOG_LEVEL"),
+			Address:     viper.GetString("SERVER_ADDRESS"),
+			LogLevel:    viper.GetString("LOG_LEVEL"),
+			Environment: strings.ToLower(viper.GetString("APP_ENV")),
+		},
+		Cookie: CookieConfig{
+			Secure:         viper.GetBool("COOKIE_SECURE"),
+			Domain:         viper.GetString("COOKIE_DOMAIN"),
+			SameSite:       strings.ToLower(viper.GetString("COOKIE_SAMESITE")),
+			TrustedOrigins: splitList(viper.GetString("CSRF_TRUSTED_ORIGINS")),
 		},
 		Database: DatabaseConfig{
 			URL:            viper.GetString("DATABASE_URL"),
 			MigrationsPath: viper.GetString("MIGRATIONS_PATH"),
 		},
 		JWT: JWTConfig{
-			Secret:               viper.GetString("JWT_SECRET"),
+			Keys:                 parseJWTKeys(viper.GetString("JWT_KEYS")),
+			SigningKeyID:         viper.GetString("JWT_SIGNING_KEY_ID"),
+			Issuer:               viper.GetString("JWT_ISSUER"),
+			Audience:             viper.GetString("JWT_AUDIENCE"),
+			Leeway:               viper.GetDuration("JWT_LEEWAY"),
 			RefreshTokenDuration: viper.GetDuration("ACCESS_TOKEN_CODE_EXPIRY"),
 			AccessTokenDuration:  viper.GetDuration("REFRESH_TOKEN_DURATION"),
+			RevocationCacheTTL:   viper.GetDuration("REVOCATION_CACHE_TTL"),
+		},
+		Mail: MailConfig{
+			Driver:       viper.GetString("MAIL_DRIVER"),
+			From:         viper.GetString("MAIL_FROM"),
+			SMTPHost:     viper.GetString("SMTP_HOST"),
+			SMTPPort:     viper.GetInt("SMTP_PORT"),
+			SMTPUsername: viper.GetString("SMTP_USERNAME"),
+			SMTPPassword: viper.GetString("SMTP_PASSWORD"),
+			LogPath:      viper.GetString("MAIL_LOG_PATH"),
+		},
+		PasswordReset: PasswordResetConfig{
+			URL:      viper.GetString("PASSWORD_RESET_URL"),
+			TokenTTL: viper.GetDuration("PASSWORD_RESET_TOKEN_TTL"),
+		},
+		MagicLink: MagicLinkConfig{
+			URL:         viper.GetString("MAGIC_LINK_URL"),
+			TokenTTL:    viper.GetDuration("MAGIC_LINK_TTL"),
+			MaxPerEmail: viper.GetInt("MAGIC_LINK_MAX_PER_EMAIL"),
+			MaxPerIP:    viper.GetInt("MAGIC_LINK_MAX_PER_IP"),
+			RateWindow:  viper.GetDuration("MAGIC_LINK_RATE_WINDOW"),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    viper.GetString("WEBAUTHN_RP_ID"),
+			RPName:  viper.GetString("WEBAUTHN_RP_NAME"),
+			Origins: splitList(viper.GetString("WEBAUTHN_ORIGINS")),
+			Timeout: viper.GetDuration("WEBAUTHN_TIMEOUT"),
+		},
+		TaskProof: TaskProofConfig{
+			Dir:      viper.GetString("TASK_PROOF_DIR"),
+			MaxBytes: viper.GetInt64("TASK_PROOF_MAX_BYTES"),
+		},
+		Email: EmailConfig{
+			Required:               viper.GetBool("EMAIL_REQUIRED"),
+			VerificationURL:        viper.GetString("EMAIL_VERIFICATION_URL"),
+			VerificationTokenTTL:   viper.GetDuration("EMAIL_VERIFICATION_TOKEN_TTL"),
+			UnverifiedRestrictions: splitList(viper.GetString("EMAIL_UNVERIFIED_RESTRICTIONS")),
+		},
+		Login: LoginProtectionConfig{
+			FreeAttempts:    viper.GetInt("LOGIN_FREE_ATTEMPTS"),
+			MaxAttempts:     viper.GetInt("LOGIN_MAX_ATTEMPTS"),
+			IPMaxAttempts:   viper.GetInt("LOGIN_IP_MAX_ATTEMPTS"),
+			BackoffBase:     viper.GetDuration("LOGIN_BACKOFF_BASE"),
+			LockoutDuration: viper.GetDuration("LOGIN_LOCKOUT_DURATION"),
+			AttemptWindow:   viper.GetDuration("LOGIN_ATTEMPT_WINDOW"),
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:       viper.GetString("TWO_FACTOR_ISSUER"),
+			ChallengeTTL: viper.GetDuration("TWO_FACTOR_CHALLENGE_TTL"),
+		},
+		Password: PasswordPolicyConfig{
+			MinLength:        viper.GetInt("PASSWORD_MIN_LENGTH"),
+			MaxBytes:         viper.GetInt("PASSWORD_MAX_BYTES"),
+			RequiredClasses:  splitList(viper.GetString("PASSWORD_REQUIRED_CLASSES")),
+			BreachedListPath: viper.GetString("PASSWORD_BREACHED_LIST_PATH"),
+		},
+		PasswordHash: PasswordHashConfig{
+			Memory:      viper.GetUint32("ARGON2_MEMORY_KIB"),
+			Iterations:  viper.GetUint32("ARGON2_ITERATIONS"),
+			Parallelism: viper.GetUint8("ARGON2_PARALLELISM"),
+		},
+		OIDC: OIDCConfig{
+			Providers: loadOIDCProviders(viper.GetString("OIDC_PROVIDERS")),
+			StateTTL:  viper.GetDuration("OIDC_STATE_TTL"),
+		},
+		Admin: AdminBootstrapConfig{
+			Username: viper.GetString("BOOTSTRAP_ADMIN_USERNAME"),
+			Password: viper.GetString("BOOTSTRAP_ADMIN_PASSWORD"),
+		},
+		Impersonation: ImpersonationConfig{
+			TokenTTL: viper.GetDuration("IMPERSONATION_TOKEN_TTL"),
+		},
+		Account: AccountConfig{
+			DeletionGracePeriod: viper.GetDuration("ACCOUNT_DELETION_GRACE_PERIOD"),
+			PurgeInterval:       viper.GetDuration("ACCOUNT_PURGE_INTERVAL"),
+		},
+		Guest: GuestConfig{
+			Retention:       viper.GetDuration("GUEST_RETENTION"),
+			CleanupInterval: viper.GetDuration("GUEST_CLEANUP_INTERVAL"),
+			MaxPerIP:        viper.GetInt("GUEST_MAX_PER_IP"),
+			RateWindow:      viper.GetDuration("GUEST_RATE_WINDOW"),
 		},
 	}
 
+	encryptionKey, err := base64.StdEncoding.DecodeString(viper.GetString("TWO_FACTOR_ENCRYPTION_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("TWO_FACTOR_ENCRYPTION_KEY must be base64: %w", err)
+	}
+	cfg.TwoFactor.EncryptionKey = encryptionKey
+
 	if err := validateConfig(cfg); err != nil {
 		return nil, err
 	}
@@ -71,7 +358,53 @@ func LoadConfig() (*Config, error) {
 func setDefaults() {
 	viper.SetDefault("SERVER_ADDRES", ":8080")
 	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("APP_ENV", "production")
 	viper.SetDefault("MIGRATIONS_PATH", "internal/db/migrations")
+	viper.SetDefault("COOKIE_SAMESITE", "lax")
+	viper.SetDefault("REVOCATION_CACHE_TTL", "30s")
+	viper.SetDefault("JWT_ISSUER", "user-service")
+	viper.SetDefault("JWT_AUDIENCE", "user-service")
+	viper.SetDefault("JWT_LEEWAY", "30s")
+	viper.SetDefault("MAIL_DRIVER", "log")
+	viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("PASSWORD_RESET_URL", "http://localhost:8080/password/reset")
+	viper.SetDefault("PASSWORD_RESET_TOKEN_TTL", "30m")
+	viper.SetDefault("MAGIC_LINK_URL", "http://localhost:8080/login/magic-link")
+	viper.SetDefault("MAGIC_LINK_TTL", "10m")
+	viper.SetDefault("MAGIC_LINK_MAX_PER_EMAIL", 5)
+	viper.SetDefault("MAGIC_LINK_MAX_PER_IP", 20)
+	viper.SetDefault("MAGIC_LINK_RATE_WINDOW", "1h")
+	viper.SetDefault("WEBAUTHN_RP_ID", "localhost")
+	viper.SetDefault("WEBAUTHN_RP_NAME", "User Service")
+	viper.SetDefault("WEBAUTHN_ORIGINS", "http://localhost:8080")
+	viper.SetDefault("WEBAUTHN_TIMEOUT", "5m")
+	viper.SetDefault("TASK_PROOF_DIR", "data/task-proofs")
+	viper.SetDefault("TASK_PROOF_MAX_BYTES", 5<<20)
+	viper.SetDefault("EMAIL_VERIFICATION_URL", "http://localhost:8080/verify-email")
+	viper.SetDefault("EMAIL_VERIFICATION_TOKEN_TTL", "24h")
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_MAX_ATTEMPTS", 10)
+	viper.SetDefault("LOGIN_IP_MAX_ATTEMPTS", 100)
+	viper.SetDefault("LOGIN_BACKOFF_BASE", "1s")
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_ATTEMPT_WINDOW", "1h")
+	viper.SetDefault("TWO_FACTOR_ISSUER", "User Service")
+	viper.SetDefault("TWO_FACTOR_CHALLENGE_TTL", "5m")
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_MAX_BYTES", 72)
+	viper.SetDefault("PASSWORD_REQUIRED_CLASSES", "letter,digit")
+	viper.SetDefault("ARGON2_MEMORY_KIB", 64*1024)
+	viper.SetDefault("ARGON2_ITERATIONS", 3)
+	viper.SetDefault("ARGON2_PARALLELISM", 2)
+	viper.SetDefault("OIDC_STATE_TTL", "10m")
+	viper.SetDefault("IMPERSONATION_TOKEN_TTL", "15m")
+	viper.SetDefault("ACCOUNT_DELETION_GRACE_PERIOD", "720h")
+	viper.SetDefault("ACCOUNT_PURGE_INTERVAL", "1h")
+	viper.SetDefault("GUEST_RETENTION", "720h")
+	viper.SetDefault("GUEST_CLEANUP_INTERVAL", "1h")
+	viper.SetDefault("GUEST_MAX_PER_IP", 10)
+	viper.SetDefault("GUEST_RATE_WINDOW", "1h")
 }
 
 func validateConfig(cfg *Config) error {
@@ -83,5 +416,168 @@ func validateConfig(cfg *Config) error {
 		return errors.New("MIGRATIONS_PATH is required field")
 	}
 
+	switch cfg.Cookie.SameSite {
+	case "lax", "strict":
+	case "none":
+		if !cfg.Cookie.Secure {
+			return errors.New("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+		}
+	default:
+		return errors.New("COOKIE_SAMESITE must be lax, strict or none")
+	}
+
+	if cfg.Mail.Driver == "smtp" && cfg.Mail.SMTPHost == "" {
+		return errors.New("SMTP_HOST is required when MAIL_DRIVER=smtp")
+	}
+
+	switch {
+	case len(cfg.TwoFactor.EncryptionKey) == 32:
+	case len(cfg.TwoFactor.EncryptionKey) == 0 && !cfg.Server.Development():
+		return errors.New("TWO_FACTOR_ENCRYPTION_KEY is required unless APP_ENV=development")
+	case len(cfg.TwoFactor.EncryptionKey) != 0:
+		return errors.New("TWO_FACTOR_ENCRYPTION_KEY must be 32 bytes encoded as base64")
+	}
+
+	if cfg.MagicLink.TokenTTL <= 0 || cfg.MagicLink.TokenTTL > time.Hour {
+		return errors.New("MAGIC_LINK_TTL must be positive and at most 1h")
+	}
+
+	if cfg.MagicLink.MaxPerEmail < 1 || cfg.MagicLink.MaxPerIP < 1 || cfg.MagicLink.RateWindow <= 0 {
+		return errors.New("MAGIC_LINK_MAX_PER_EMAIL, MAGIC_LINK_MAX_PER_IP and MAGIC_LINK_RATE_WINDOW must be positive")
+	}
+
+	if cfg.Guest.MaxPerIP < 1 || cfg.Guest.RateWindow <= 0 {
+		return errors.New("GUEST_MAX_PER_IP and GUEST_RATE_WINDOW must be positive")
+	}
+
+	if cfg.WebAuthn.RPID == "" || len(cfg.WebAuthn.Origins) == 0 {
+		return errors.New("WEBAUTHN_RP_ID and WEBAUTHN_ORIGINS are required")
+	}
+
+	if cfg.WebAuthn.Timeout <= 0 || cfg.WebAuthn.Timeout > 10*time.Minute {
+		return errors.New("WEBAUTHN_TIMEOUT must be positive and at most 10m")
+	}
+
+	if cfg.TaskProof.Dir == "" {
+		return errors.New("TASK_PROOF_DIR is required")
+	}
+
+	if cfg.TaskProof.MaxBytes <= 0 || cfg.TaskProof.MaxBytes > 50<<20 {
+		return errors.New("TASK_PROOF_MAX_BYTES must be positive and at most 50 MiB")
+	}
+
+	if cfg.Login.MaxAttempts <= cfg.Login.FreeAttempts {
+		return errors.New("LOGIN_MAX_ATTEMPTS must be greater than LOGIN_FREE_ATTEMPTS")
+	}
+
+	if cfg.Password.MinLength < 1 {
+		return errors.New("PASSWORD_MIN_LENGTH must be positive")
+	}
+
+	for _, provider := range cfg.OIDC.Providers {
+		if provider.DiscoveryURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return fmt.Errorf("OIDC provider %q needs a discovery URL, client id and redirect URL", provider.Name)
+		}
+	}
+
+	if cfg.Impersonation.TokenTTL <= 0 || cfg.Impersonation.TokenTTL > time.Hour {
+		return errors.New("IMPERSONATION_TOKEN_TTL must be positive and at most 1h")
+	}
+
+	if cfg.Account.DeletionGracePeriod < 0 {
+		return errors.New("ACCOUNT_DELETION_GRACE_PERIOD must not be negative")
+	}
+
+	if cfg.Account.PurgeInterval <= 0 {
+		return errors.New("ACCOUNT_PURGE_INTERVAL must be positive")
+	}
+
+	if cfg.Guest.Retention <= 0 || cfg.Guest.CleanupInterval <= 0 {
+		return errors.New("GUEST_RETENTION and GUEST_CLEANUP_INTERVAL must be positive")
+	}
+
+	if cfg.JWT.Issuer == "" || cfg.JWT.Audience == "" {
+		return errors.New("JWT_ISSUER and JWT_AUDIENCE are required fields")
+	}
+
+	if len(cfg.JWT.Keys) == 1 && cfg.JWT.SigningKeyID == "" {
+		cfg.JWT.SigningKeyID = cfg.JWT.Keys[0].ID
+	}
+
+	if len(cfg.JWT.Keys) > 0 {
+		found := false
+		for _, key := range cfg.JWT.Keys {
+			if key.ID == "" || key.Path == "" {
+				return errors.New("JWT_KEYS entries must look like kid:path")
+			}
+			if key.ID == cfg.JWT.SigningKeyID {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("JWT_SIGNING_KEY_ID %q is not listed in JWT_KEYS", cfg.JWT.SigningKeyID)
+		}
+	}
+
 	return nil
 }
+
+func splitList(raw string) []string {
+	var items []string
+
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// loadOIDCProviders reads the settings of every provider listed in
+// OIDC_PROVIDERS from OIDC_<NAME>_* variables.
+func loadOIDCProviders(raw string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+
+	for _, name := range splitList(raw) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		scopes := splitList(viper.GetString(prefix + "SCOPES"))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			DiscoveryURL: viper.GetString(prefix + "DISCOVERY_URL"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			Scopes:       scopes,
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			TrustEmail:   viper.GetBool(prefix + "TRUST_EMAIL"),
+		})
+	}
+
+	return providers
+}
+
+// parseJWTKeys reads a comma separated list of kid:path pairs.
+func parseJWTKeys(raw string) []JWTKeyConfig {
+	var keys []JWTKeyConfig
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, path, _ := strings.Cut(entry, ":")
+		keys = append(keys, JWTKeyConfig{
+			ID:   strings.TrimSpace(id),
+			Path: strings.TrimSpace(path),
+		})
+	}
+
+	return keys
+}
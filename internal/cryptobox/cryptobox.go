@@ -0,0 +1,84 @@
+// Package cryptobox encrypts small secrets, such as TOTP seeds, before they
+// are written to the database.
+package cryptobox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const version = "v1"
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New expects a 32 byte key and uses AES-256-GCM.
+func New(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// NewEphemeral uses a random key. Secrets sealed with it cannot be opened
+// after a restart, so it is only meant for local development.
+func NewEphemeral() (*Box, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return New(key)
+}
+
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, plaintext, nil)
+
+	return version + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Open(ciphertext string) ([]byte, error) {
+	prefix, payload, ok := strings.Cut(ciphertext, ":")
+	if !ok || prefix != version {
+		return nil, errors.New("unsupported ciphertext format")
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("invalid ciphertext")
+	}
+
+	plaintext, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
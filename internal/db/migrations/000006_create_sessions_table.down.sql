@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_token VARCHAR(500);
+CREATE INDEX IF NOT EXISTS idx_users_refresh_token ON users(refresh_token);
+
+-- Each session turns back into the token family it was made of.
+ALTER TABLE refresh_tokens ADD COLUMN family_id VARCHAR(64);
+UPDATE refresh_tokens SET family_id = 'session-' || session_id;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+DROP INDEX IF EXISTS idx_refresh_tokens_session_id;
+ALTER TABLE refresh_tokens DROP COLUMN session_id;
+
+DROP INDEX IF EXISTS idx_sessions_user_id;
+DROP TABLE IF EXISTS sessions CASCADE;
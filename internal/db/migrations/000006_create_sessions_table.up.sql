@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_name VARCHAR(255),
+    user_agent VARCHAR(512),
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+
+-- Every token family issued before sessions existed becomes a session of its
+-- own, so nobody is signed out by the upgrade. The device is unknown.
+ALTER TABLE sessions ADD COLUMN family_id VARCHAR(64);
+
+INSERT INTO sessions (user_id, family_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT user_id,
+       family_id,
+       MIN(created_at),
+       MAX(COALESCE(rotated_at, created_at)),
+       MAX(expires_at),
+       CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY user_id, family_id;
+
+ALTER TABLE refresh_tokens ADD COLUMN session_id INTEGER REFERENCES sessions(id) ON DELETE CASCADE;
+
+UPDATE refresh_tokens t SET session_id = s.id
+FROM sessions s
+WHERE s.family_id = t.family_id AND s.user_id = t.user_id;
+
+ALTER TABLE refresh_tokens ALTER COLUMN session_id SET NOT NULL;
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
+ALTER TABLE sessions DROP COLUMN family_id;
+
+DROP INDEX IF EXISTS idx_users_refresh_token;
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_token;
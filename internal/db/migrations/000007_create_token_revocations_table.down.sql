@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_token_revocations CASCADE;
+DROP INDEX IF EXISTS idx_revoked_tokens_expires_at;
+DROP TABLE IF EXISTS revoked_tokens CASCADE;
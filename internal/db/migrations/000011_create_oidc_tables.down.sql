@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+DROP TABLE IF EXISTS user_identities CASCADE;
+DROP INDEX IF EXISTS idx_oidc_auth_requests_expires_at;
+DROP TABLE IF EXISTS oidc_auth_requests CASCADE;
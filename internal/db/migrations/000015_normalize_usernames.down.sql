@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_username_lower;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+CREATE INDEX idx_users_username ON users(username);
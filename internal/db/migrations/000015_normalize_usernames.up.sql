@@ -0,0 +1,51 @@
+-- Names are now compared and stored in their canonical form: NFKC normalized
+-- and lower case, as username.Canonical does. Existing names are brought to
+-- it as well, so every account can still be found by its name and the unique
+-- index below sees every clash, not only the ones in case.
+ALTER TABLE users ADD COLUMN canonical_username TEXT;
+UPDATE users SET canonical_username = LOWER(NORMALIZE(TRIM(username), NFKC));
+
+-- Of the accounts whose names clash, the oldest keeps the name and later ones
+-- get their id appended, cutting the name so the result stays within the 32
+-- character limit. Should that name be taken too, a counter is added until
+-- it is free.
+DO $$
+DECLARE
+    clash RECORD;
+    suffix TEXT;
+    candidate TEXT;
+    attempt INTEGER;
+BEGIN
+    FOR clash IN
+        SELECT u.id, u.canonical_username AS name FROM users u
+        WHERE EXISTS (
+            SELECT 1 FROM users o
+            WHERE o.canonical_username = u.canonical_username AND o.id < u.id
+        )
+        ORDER BY u.id
+    LOOP
+        attempt := 0;
+        LOOP
+            suffix := '-' || clash.id;
+            IF attempt > 0 THEN
+                suffix := suffix || '-' || attempt;
+            END IF;
+
+            candidate := LEFT(clash.name, 32 - LENGTH(suffix)) || suffix;
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM users WHERE canonical_username = candidate);
+            attempt := attempt + 1;
+        END LOOP;
+
+        UPDATE users SET canonical_username = candidate WHERE id = clash.id;
+    END LOOP;
+END $$;
+
+-- The exact-match constraint is checked row by row and would trip over a
+-- name passed from one account to another halfway through the update.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+DROP INDEX IF EXISTS idx_users_username;
+
+UPDATE users SET username = canonical_username WHERE username <> canonical_username;
+ALTER TABLE users DROP COLUMN canonical_username;
+
+CREATE UNIQUE INDEX idx_users_username_lower ON users(LOWER(username));
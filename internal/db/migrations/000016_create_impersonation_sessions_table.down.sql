@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_impersonation_sessions_created_at;
+DROP INDEX IF EXISTS idx_impersonation_sessions_target_user_id;
+DROP INDEX IF EXISTS idx_impersonation_sessions_actor_id;
+DROP TABLE IF EXISTS impersonation_sessions CASCADE;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    actor_username VARCHAR(255) NOT NULL,
+    target_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    target_username VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    read_only BOOLEAN NOT NULL DEFAULT TRUE,
+    token_id VARCHAR(64) UNIQUE NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    ended_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_impersonation_sessions_actor_id ON impersonation_sessions(actor_id);
+CREATE INDEX idx_impersonation_sessions_target_user_id ON impersonation_sessions(target_user_id);
+CREATE INDEX idx_impersonation_sessions_created_at ON impersonation_sessions(created_at DESC);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_balance_transactions_user_id;
+DROP TABLE IF EXISTS balance_transactions CASCADE;
+
+DROP INDEX IF EXISTS idx_users_purge_after;
+ALTER TABLE users DROP COLUMN IF EXISTS purge_after;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
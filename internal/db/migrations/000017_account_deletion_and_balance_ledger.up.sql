@@ -0,0 +1,24 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS purge_after TIMESTAMP;
+
+CREATE INDEX idx_users_purge_after ON users(purge_after) WHERE purge_after IS NOT NULL;
+
+-- Ledger rows outlive the users they mention: once a deleted account is
+-- purged the amounts stay, only the links are cleared.
+CREATE TABLE IF NOT EXISTS balance_transactions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    amount INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    task_id INTEGER REFERENCES tasks(id) ON DELETE SET NULL,
+    related_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_balance_transactions_user_id ON balance_transactions(user_id, created_at);
+
+-- Balances earned before the ledger existed cannot be broken down, so they
+-- are carried over as a single opening entry.
+INSERT INTO balance_transactions (user_id, amount, balance_after, reason)
+SELECT id, balance, balance, 'opening_balance' FROM users WHERE balance <> 0;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS guest_credentials CASCADE;
+
+DROP INDEX IF EXISTS idx_users_is_guest;
+ALTER TABLE users DROP COLUMN IF EXISTS is_guest;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_magic_links_ip_created_at;
+DROP INDEX IF EXISTS idx_magic_links_email_created_at;
+DROP INDEX IF EXISTS idx_magic_links_user_id;
+DROP TABLE IF EXISTS magic_links CASCADE;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS magic_links (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_id VARCHAR(64) UNIQUE NOT NULL,
+    binding_hash VARCHAR(64) NOT NULL,
+    ip_address VARCHAR(64),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_magic_links_user_id ON magic_links(user_id);
+CREATE INDEX idx_magic_links_email_created_at ON magic_links(LOWER(email), created_at);
+CREATE INDEX idx_magic_links_ip_created_at ON magic_links(ip_address, created_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_tasks_active;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_title_not_blank;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS created_at;
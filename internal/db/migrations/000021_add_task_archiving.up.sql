@@ -0,0 +1,7 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+ALTER TABLE tasks ADD CONSTRAINT tasks_title_not_blank CHECK (btrim(title) <> '');
+
+CREATE INDEX idx_tasks_active ON tasks(id) WHERE archived_at IS NULL;
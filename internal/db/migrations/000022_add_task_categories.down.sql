@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_tasks_active;
+CREATE INDEX idx_tasks_active ON tasks(id) WHERE archived_at IS NULL;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS category;
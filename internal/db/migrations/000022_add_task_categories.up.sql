@@ -0,0 +1,4 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS category VARCHAR(50) NOT NULL DEFAULT 'general';
+
+DROP INDEX IF EXISTS idx_tasks_active;
+CREATE INDEX idx_tasks_active ON tasks(category, id) WHERE archived_at IS NULL;
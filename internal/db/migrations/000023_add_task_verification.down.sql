@@ -0,0 +1,23 @@
+DROP INDEX IF EXISTS idx_user_tasks_status;
+
+-- Submissions that were never approved earned nothing and have no place in
+-- the old schema.
+DELETE FROM user_tasks WHERE status <> 'approved';
+
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS rejection_reason;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS proof_content_type;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS proof_file_name;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS proof_file_key;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS proof_url;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS proof_text;
+
+ALTER TABLE user_tasks ALTER COLUMN completed_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS submitted_at;
+
+ALTER TABLE user_tasks DROP CONSTRAINT IF EXISTS user_tasks_status_check;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS status;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_verification_mode_check;
+ALTER TABLE tasks DROP COLUMN IF EXISTS verification_mode;
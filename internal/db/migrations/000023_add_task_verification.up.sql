@@ -0,0 +1,29 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS verification_mode VARCHAR(20) NOT NULL DEFAULT 'auto';
+ALTER TABLE tasks ADD CONSTRAINT tasks_verification_mode_check
+    CHECK (verification_mode IN ('auto', 'proof', 'external'));
+
+-- Completions recorded so far were credited right away, so they stay
+-- approved; new rows are written with an explicit status.
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved';
+ALTER TABLE user_tasks ADD CONSTRAINT user_tasks_status_check
+    CHECK (status IN ('pending', 'approved', 'rejected'));
+ALTER TABLE user_tasks ALTER COLUMN status SET DEFAULT 'pending';
+
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP;
+UPDATE user_tasks SET submitted_at = COALESCE(completed_at, CURRENT_TIMESTAMP);
+ALTER TABLE user_tasks ALTER COLUMN submitted_at SET NOT NULL;
+ALTER TABLE user_tasks ALTER COLUMN submitted_at SET DEFAULT CURRENT_TIMESTAMP;
+
+-- completed_at is now the time of approval.
+ALTER TABLE user_tasks ALTER COLUMN completed_at DROP DEFAULT;
+
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS proof_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS proof_url VARCHAR(2048) NOT NULL DEFAULT '';
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS proof_file_key VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS proof_file_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS proof_content_type VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+ALTER TABLE user_tasks ADD COLUMN IF NOT EXISTS rejection_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_user_tasks_status ON user_tasks(status, id);
@@ -0,0 +1,4 @@
+-- Revoking every token of a user bumps the generation; access tokens carry
+-- the generation they were issued in. Rows written so far stand for one
+-- revocation each.
+ALTER TABLE user_token_revocations ADD COLUMN IF NOT EXISTS generation INTEGER NOT NULL DEFAULT 1;
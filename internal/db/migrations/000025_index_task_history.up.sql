@@ -0,0 +1,2 @@
+-- The task history is paged by submission time.
+CREATE INDEX IF NOT EXISTS idx_user_tasks_user_submitted ON user_tasks(user_id, submitted_at DESC, id DESC);
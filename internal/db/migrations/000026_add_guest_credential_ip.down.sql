@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_guest_credentials_ip_created_at;
+ALTER TABLE guest_credentials DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,4 @@
+-- Guest creation is limited per client IP, like sign-in links.
+ALTER TABLE guest_credentials ADD COLUMN IF NOT EXISTS ip_address VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_guest_credentials_ip_created_at ON guest_credentials(ip_address, created_at);
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey is a long-lived credential for scripts and other services. Only a
+// hash of the key is stored; Prefix is the public part that identifies it in
+// listings and logs. Scopes are kept as a comma separated list.
+type APIKey struct {
+	ID         int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int        `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"uniqueIndex;not null" json:"prefix"`
+	KeyHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"not null;default:''" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"column:last_used_ip" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+
+	return strings.Split(k.Scopes, ",")
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// Reasons of balance changes.
+const (
+	BalanceReasonOpening       = "opening_balance"
+	BalanceReasonTaskCompleted = "task_completed"
+	BalanceReasonReferralBonus = "referral_bonus"
+)
+
+// BalanceTransaction is one entry of the balance ledger. UserID is nil once
+// the account it belonged to has been purged. RelatedUserID is the other
+// user involved, such as the referral that earned a bonus.
+type BalanceTransaction struct {
+	ID            int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        *int      `gorm:"index" json:"user_id,omitempty"`
+	Amount        int       `gorm:"not null" json:"amount"`
+	BalanceAfter  int       `gorm:"not null" json:"balance_after"`
+	Reason        string    `gorm:"not null" json:"reason"`
+	TaskID        *int      `json:"task_id,omitempty"`
+	RelatedUserID *int      `json:"related_user_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (BalanceTransaction) TableName() string {
+	return "balance_transactions"
+}
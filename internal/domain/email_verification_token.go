@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// EmailVerificationToken proves that the user can read mail sent to Email.
+// The address is kept on the token so that a link mailed to an address the
+// user has changed since then no longer verifies anything.
+type EmailVerificationToken struct {
+	ID        int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int        `gorm:"not null;index" json:"user_id"`
+	Email     string     `gorm:"not null" json:"email"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (EmailVerificationToken) TableName() string {
+	return "email_verification_tokens"
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// GuestCredential is the device-bound secret of a guest account, the only way
+// to sign in to it again once its session has expired. Only a hash of the
+// secret is stored.
+type GuestCredential struct {
+	UserID     int       `gorm:"primaryKey" json:"user_id"`
+	SecretHash string    `gorm:"uniqueIndex;not null" json:"-"`
+	DeviceName string    `json:"device_name"`
+	IPAddress  string    `gorm:"column:ip_address" json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func (GuestCredential) TableName() string {
+	return "guest_credentials"
+}
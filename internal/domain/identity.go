@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// OIDCAuthRequest keeps the secrets of an authorization request between the
+// redirect to the provider and the callback. LinkUserID is set when a signed
+// in user connects another provider to their account.
+type OIDCAuthRequest struct {
+	StateHash    string    `gorm:"primaryKey" json:"-"`
+	Provider     string    `gorm:"not null" json:"provider"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	LinkUserID   *int      `json:"link_user_id,omitempty"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OIDCAuthRequest) TableName() string {
+	return "oidc_auth_requests"
+}
+
+// UserIdentity links a local user to an account at an external provider.
+type UserIdentity struct {
+	ID          int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int        `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"not null" json:"provider"`
+	Subject     string     `gorm:"not null" json:"subject"`
+	Email       *string    `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
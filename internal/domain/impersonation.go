@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// ImpersonationSession is the audit record of a staff member acting as
+// another user. Usernames are copied so the record stays readable after
+// either account is deleted. TokenID is the jti of the access token that was
+// handed out.
+type ImpersonationSession struct {
+	ID             int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID        *int       `gorm:"index" json:"actor_id"`
+	ActorUsername  string     `gorm:"not null" json:"actor_username"`
+	TargetUserID   *int       `gorm:"index" json:"target_user_id"`
+	TargetUsername string     `gorm:"not null" json:"target_username"`
+	Reason         string     `gorm:"not null" json:"reason"`
+	ReadOnly       bool       `gorm:"not null;default:true" json:"read_only"`
+	TokenID        string     `gorm:"uniqueIndex;not null" json:"-"`
+	IPAddress      string     `json:"ip_address,omitempty"`
+	UserAgent      string     `json:"user_agent,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	EndedBy        *int       `json:"ended_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (ImpersonationSession) TableName() string {
+	return "impersonation_sessions"
+}
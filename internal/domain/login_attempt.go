@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// LoginAttempt counts recent failed logins for one key, which is either a
+// username or a client IP.
+type LoginAttempt struct {
+	Key           string     `gorm:"primaryKey" json:"key"`
+	Failures      int        `gorm:"not null" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until,omitempty"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
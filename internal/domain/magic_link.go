@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// MagicLink records a sign-in link sent by mail. TokenID is the jti of the
+// signed token in the link, BindingHash the hash of the secret the requesting
+// device keeps. Requests for unknown addresses are recorded too, without a
+// user, so they count towards the rate limits.
+type MagicLink struct {
+	ID          int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      *int       `gorm:"index" json:"user_id,omitempty"`
+	Email       string     `gorm:"not null" json:"email"`
+	TokenID     string     `gorm:"uniqueIndex;not null" json:"-"`
+	BindingHash string     `gorm:"not null" json:"-"`
+	IPAddress   string     `gorm:"column:ip_address" json:"ip_address"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (MagicLink) TableName() string {
+	return "magic_links"
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey" json:"jti"`
+	UserID    int       `gorm:"not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// UserTokenRevocation counts how many times all tokens of a user were
+// revoked. Access tokens of an older generation are no longer accepted.
+// RevokedBefore is the time of the latest revocation.
+type UserTokenRevocation struct {
+	UserID        int       `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	RevokedBefore time.Time `gorm:"not null" json:"revoked_before"`
+	Generation    int       `gorm:"not null" json:"generation"`
+}
+
+func (UserTokenRevocation) TableName() string {
+	return "user_token_revocations"
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+type Session struct {
+	ID         int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int        `gorm:"not null;index" json:"user_id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `gorm:"column:ip_address" json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
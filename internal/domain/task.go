@@ -1,25 +1,93 @@
 package domain
 
+import "time"
+
+// Task is something users complete for points. Archived tasks are hidden
+// and can no longer be completed, but the completions stay.
 type Task struct {
-	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string `gorm:"not null" json:"title"`
-	Description string `gorm:"type:text" json:"description"`
-	Points      int    `gorm:"not null" json:"points"`
+	ID               int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title            string     `gorm:"not null" json:"title"`
+	Description      string     `gorm:"type:text" json:"description"`
+	Category         string     `gorm:"not null;default:general" json:"category"`
+	Points           int        `gorm:"not null" json:"points"`
+	VerificationMode string     `gorm:"not null;default:auto" json:"verification_mode"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty"`
 }
 
 func (Task) TableName() string {
 	return "tasks"
 }
 
+func (t *Task) Archived() bool {
+	return t.ArchivedAt != nil
+}
+
+const DefaultTaskCategory = "general"
+
+// Verification modes of tasks. Auto completions are approved at once, proof
+// needs a text, link or file from the user and external a moderator's check
+// against a system outside the service; both wait for review.
+const (
+	TaskVerificationAuto     = "auto"
+	TaskVerificationProof    = "proof"
+	TaskVerificationExternal = "external"
+)
+
+// Statuses of task submissions. Points are credited on approval only.
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// Completion states a task catalog can be filtered by.
+const (
+	TaskStatusCompleted = "completed"
+	TaskStatusPending   = "pending"
+	TaskStatusAvailable = "available"
+)
+
+// TaskWithStatus is a task as one user sees it. SubmissionStatus is set when
+// the user has submitted the task, CompletedAt once it was approved.
+type TaskWithStatus struct {
+	Task
+	SubmissionStatus *string    `gorm:"column:submission_status"`
+	CompletedAt      *time.Time `gorm:"column:completed_at"`
+}
+
+func (t *TaskWithStatus) Completed() bool {
+	return t.SubmissionStatus != nil && *t.SubmissionStatus == SubmissionApproved
+}
+
+// UserTask is the submission of a task by a user. A rejected submission can
+// be sent again, which replaces its proof and puts it back in review.
+// ProofFileKey names the uploaded file in the proof store.
 type UserTask struct {
-	ID     int `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID int `gorm:"not null;index" json:"user_id"`
-	TaskID int `gorm:"nott null;index" json:"task_id"`
+	ID               int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           int        `gorm:"not null;index" json:"user_id"`
+	TaskID           int        `gorm:"not null;index" json:"task_id"`
+	Status           string     `gorm:"not null" json:"status"`
+	ProofText        string     `gorm:"type:text;not null" json:"proof_text"`
+	ProofURL         string     `gorm:"column:proof_url;not null" json:"proof_url"`
+	ProofFileKey     string     `gorm:"not null" json:"-"`
+	ProofFileName    string     `gorm:"not null" json:"proof_file_name"`
+	ProofContentType string     `gorm:"not null" json:"proof_content_type"`
+	SubmittedAt      time.Time  `gorm:"not null" json:"submitted_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	ReviewedBy       *int       `json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason  string     `gorm:"type:text;not null" json:"rejection_reason"`
 
 	User User `gorm:"foreignKey:UserID" json:"-"`
 	Task Task `gorm:"foreignKey:TaskID" json:"-"`
 }
 
+func (t *UserTask) HasProofFile() bool {
+	return t.ProofFileKey != ""
+}
+
 func (UserTask) TableName() string {
 	return "user_tasks"
 }
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+type UserTOTP struct {
+	UserID          int        `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	SecretEncrypted string     `gorm:"not null" json:"-"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep    int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+type RecoveryCode struct {
+	ID        int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int        `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
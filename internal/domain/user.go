@@ -1,12 +1,20 @@
 package domain
 
+import "time"
+
 type User struct {
-	ID           int    `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username     string `gorm:"unique;not null" json:"username"`
-	PasswordHash string `gorm:"column:password_hash;not null" json:"-"`
-	Balance      int    `gorm:"default:0" json:"balance"`
-	RefreshToken string `gorm:"column:refresh_token" json:"-"`
-	ReferrerID   *int   `gorm:"index" json:"referrer_id,omitempty"`
+	ID              int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username        string     `gorm:"unique;not null" json:"username"`
+	Email           *string    `gorm:"index" json:"email,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PasswordHash    string     `gorm:"column:password_hash;not null" json:"-"`
+	Balance         int        `gorm:"default:0" json:"balance"`
+	ReferrerID      *int       `gorm:"index" json:"referrer_id,omitempty"`
+	Role            string     `gorm:"not null;default:user" json:"role"`
+	IsGuest         bool       `gorm:"not null;default:false" json:"is_guest"`
+	BannedAt        *time.Time `json:"banned_at,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter      *time.Time `json:"purge_after,omitempty"`
 
 	Referrer       *User      `gorm:"foreignKey:ReferrerID" json:"-"`
 	CompletedTasks []UserTask `gorm:"foreignKey:UserID" json:"-"`
@@ -15,3 +23,15 @@ type User struct {
 func (User) TableName() string {
 	return "users"
 }
+
+// EmailVerified reports whether the current address of the user was
+// confirmed through a verification link.
+func (u *User) EmailVerified() bool {
+	return u.Email != nil && u.EmailVerifiedAt != nil
+}
+
+// Deleted reports whether the user has deleted the account. Nothing is
+// removed before PurgeAfter, and until then the deletion can be cancelled.
+func (u *User) Deleted() bool {
+	return u.DeletedAt != nil
+}
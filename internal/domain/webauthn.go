@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// WebAuthnCredential is a passkey registered by a user. PublicKey is the
+// COSE key from the registration, SignCount the last counter the
+// authenticator reported.
+type WebAuthnCredential struct {
+	ID             int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         int        `gorm:"not null;index" json:"user_id"`
+	CredentialID   []byte     `gorm:"uniqueIndex;not null" json:"-"`
+	PublicKey      []byte     `gorm:"not null" json:"-"`
+	Algorithm      int        `gorm:"not null" json:"-"`
+	SignCount      int64      `gorm:"not null;default:0" json:"-"`
+	AAGUID         []byte     `gorm:"column:aaguid" json:"-"`
+	Transports     string     `gorm:"not null;default:''" json:"-"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackedUp       bool       `gorm:"not null;default:false" json:"backed_up"`
+	Name           string     `gorm:"not null" json:"name"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+func (c *WebAuthnCredential) TransportList() []string {
+	if c.Transports == "" {
+		return nil
+	}
+	return strings.Split(c.Transports, ",")
+}
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// WebAuthnChallenge is a pending ceremony, keyed by the hash of its
+// challenge. Login challenges have no user: the passkey names it.
+type WebAuthnChallenge struct {
+	ChallengeHash string    `gorm:"primaryKey" json:"-"`
+	UserID        *int      `gorm:"index" json:"user_id,omitempty"`
+	Ceremony      string    `gorm:"not null" json:"ceremony"`
+	ExpiresAt     time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
@@ -0,0 +1,83 @@
+package dto
+
+import "time"
+
+// DeleteAccountRequest confirms the deletion with the current password.
+// Accounts without a password send a two-factor code instead, or nothing
+// right after signing in.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type AccountDeletionResponse struct {
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// AccountExport is everything the service stores about a user, as handed out
+// by GET /api/users/me/export.
+type AccountExport struct {
+	ExportedAt     time.Time                    `json:"exported_at"`
+	Profile        ExportProfile                `json:"profile"`
+	BalanceHistory []BalanceTransactionResponse `json:"balance_history"`
+	CompletedTasks []CompletedTaskResponse      `json:"completed_tasks"`
+	Referrals      []ReferralResponse           `json:"referrals"`
+	Sessions       []ExportSession              `json:"sessions"`
+	Identities     []IdentityResponse           `json:"identities"`
+	APIKeys        []APIKeyResponse             `json:"api_keys"`
+}
+
+type ExportProfile struct {
+	ID              int        `json:"id"`
+	Username        string     `json:"username"`
+	Email           *string    `json:"email,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	Role            string     `json:"role"`
+	Guest           bool       `json:"guest"`
+	Balance         int        `json:"balance"`
+	ReferrerID      *int       `json:"referrer_id,omitempty"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+}
+
+type BalanceTransactionResponse struct {
+	ID            int       `json:"id"`
+	Amount        int       `json:"amount"`
+	BalanceAfter  int       `json:"balance_after"`
+	Reason        string    `json:"reason"`
+	TaskID        *int      `json:"task_id,omitempty"`
+	RelatedUserID *int      `json:"related_user_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CompletedTaskResponse is a submission of a task in any status. CompletedAt
+// is set once it was approved, RejectionReason once it was rejected.
+type CompletedTaskResponse struct {
+	ID              int        `json:"id"`
+	TaskID          int        `json:"task_id"`
+	Title           string     `json:"title"`
+	Category        string     `json:"category"`
+	Points          int        `json:"points"`
+	Status          string     `json:"status"`
+	SubmittedAt     time.Time  `json:"submitted_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+}
+
+// ReferralResponse is a user who named the exporting user as referrer. Only
+// what the leaderboard shows anyway is included.
+type ReferralResponse struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type ExportSession struct {
+	ID         int        `json:"id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
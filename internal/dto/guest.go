@@ -0,0 +1,25 @@
+package dto
+
+type CreateGuestRequest struct {
+	DeviceName string `json:"device_name" binding:"max=255"`
+}
+
+type GuestLoginRequest struct {
+	DeviceSecret string `json:"device_secret" binding:"required"`
+	DeviceName   string `json:"device_name" binding:"max=255"`
+}
+
+// GuestResponse is the only response that contains the device secret. The
+// client keeps it on the device to sign in again after the session expires.
+type GuestResponse struct {
+	TokenResponse
+	UserID       int    `json:"user_id"`
+	DeviceSecret string `json:"device_secret"`
+}
+
+type UpgradeGuestRequest struct {
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	DeviceName string `json:"device_name" binding:"max=255"`
+}
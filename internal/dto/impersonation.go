@@ -0,0 +1,34 @@
+package dto
+
+import "time"
+
+// ImpersonateRequest starts an impersonation. ReadOnly defaults to true, so
+// write access has to be asked for explicitly.
+type ImpersonateRequest struct {
+	Reason   string `json:"reason" binding:"required,max=500"`
+	ReadOnly *bool  `json:"read_only"`
+}
+
+type ImpersonationTokenResponse struct {
+	SessionID   int       `json:"session_id"`
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	ReadOnly    bool      `json:"read_only"`
+}
+
+type ImpersonationSessionResponse struct {
+	ID             int        `json:"id"`
+	ActorID        *int       `json:"actor_id"`
+	ActorUsername  string     `json:"actor_username"`
+	TargetUserID   *int       `json:"target_user_id"`
+	TargetUsername string     `json:"target_username"`
+	Reason         string     `json:"reason"`
+	ReadOnly       bool       `json:"read_only"`
+	IPAddress      string     `json:"ip_address,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	EndedBy        *int       `json:"ended_by,omitempty"`
+}
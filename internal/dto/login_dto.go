@@ -1,10 +1,9 @@
 package dto
 
-import "fmt"
-
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 type TokenResponse struct {
@@ -14,27 +13,51 @@ type TokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"omitempty,email"`
 }
 
-func (l *LoginRequest) Validate() error {
-	if l.Password == "" {
-		return fmt.Errorf("password must not be empty")
-	}
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
 
-	if len(l.Password) < 6 {
-		return fmt.Errorf("password must be longer than 6 symb")
-	}
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
 
-	if len(l.Username) < 3 {
-		return fmt.Errorf("username must be longer than 3 symb")
-	}
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
 
-	if l.Username == "" {
-		return fmt.Errorf("username must be not empty")
-	}
+// MagicLinkRequestedResponse carries the device binding for clients that do
+// not keep cookies. Browsers get it as the magic_link_binding cookie too.
+type MagicLinkRequestedResponse struct {
+	Message       string `json:"message"`
+	DeviceBinding string `json:"device_binding"`
+}
 
-	return nil
+type MagicLinkLoginRequest struct {
+	Token         string `json:"token" binding:"required"`
+	DeviceBinding string `json:"device_binding"`
+	DeviceName    string `json:"device_name"`
 }
@@ -4,10 +4,14 @@ import "user-service/internal/domain"
 
 func ToUserStatusResponse(user *domain.User) UserStatusResponse {
 	return UserStatusResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Balance:    user.Balance,
-		ReferrerID: user.ReferrerID,
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified(),
+		Guest:         user.IsGuest,
+		Balance:       user.Balance,
+		ReferrerID:    user.ReferrerID,
+		PurgeAfter:    user.PurgeAfter,
 	}
 }
 
@@ -19,3 +23,183 @@ func ToLeaderboardUserDTO(user *domain.User, rank int) LeaderboardUserDTO {
 		Rank:     rank,
 	}
 }
+
+func ToSessionResponse(session *domain.Session, currentSessionID int) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		DeviceName: session.DeviceName,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		Current:    session.ID == currentSessionID,
+	}
+}
+
+func ToLockoutResponse(attempt *domain.LoginAttempt) LockoutResponse {
+	response := LockoutResponse{
+		Key:           attempt.Key,
+		Failures:      attempt.Failures,
+		LastFailureAt: attempt.LastFailureAt,
+	}
+
+	if attempt.LockedUntil != nil {
+		response.LockedUntil = *attempt.LockedUntil
+	}
+
+	return response
+}
+
+func ToIdentityResponse(identity *domain.UserIdentity) IdentityResponse {
+	return IdentityResponse{
+		Provider:    identity.Provider,
+		Email:       identity.Email,
+		CreatedAt:   identity.CreatedAt,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
+
+func ToAPIKeyResponse(key *domain.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+	}
+}
+
+func ToImpersonationSessionResponse(session *domain.ImpersonationSession) ImpersonationSessionResponse {
+	return ImpersonationSessionResponse{
+		ID:             session.ID,
+		ActorID:        session.ActorID,
+		ActorUsername:  session.ActorUsername,
+		TargetUserID:   session.TargetUserID,
+		TargetUsername: session.TargetUsername,
+		Reason:         session.Reason,
+		ReadOnly:       session.ReadOnly,
+		IPAddress:      session.IPAddress,
+		CreatedAt:      session.CreatedAt,
+		ExpiresAt:      session.ExpiresAt,
+		EndedAt:        session.EndedAt,
+		EndedBy:        session.EndedBy,
+	}
+}
+
+func ToBalanceTransactionResponse(entry *domain.BalanceTransaction) BalanceTransactionResponse {
+	return BalanceTransactionResponse{
+		ID:            entry.ID,
+		Amount:        entry.Amount,
+		BalanceAfter:  entry.BalanceAfter,
+		Reason:        entry.Reason,
+		TaskID:        entry.TaskID,
+		RelatedUserID: entry.RelatedUserID,
+		CreatedAt:     entry.CreatedAt,
+	}
+}
+
+func ToCompletedTaskResponse(completion *domain.UserTask) CompletedTaskResponse {
+	return CompletedTaskResponse{
+		ID:              completion.ID,
+		TaskID:          completion.TaskID,
+		Title:           completion.Task.Title,
+		Category:        completion.Task.Category,
+		Points:          completion.Task.Points,
+		Status:          completion.Status,
+		SubmittedAt:     completion.SubmittedAt,
+		CompletedAt:     completion.CompletedAt,
+		RejectionReason: completion.RejectionReason,
+	}
+}
+
+func ToExportSession(session *domain.Session) ExportSession {
+	return ExportSession{
+		ID:         session.ID,
+		DeviceName: session.DeviceName,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		RevokedAt:  session.RevokedAt,
+	}
+}
+
+func ToPasskeyResponse(credential *domain.WebAuthnCredential) PasskeyResponse {
+	return PasskeyResponse{
+		ID:             credential.ID,
+		Name:           credential.Name,
+		BackupEligible: credential.BackupEligible,
+		BackedUp:       credential.BackedUp,
+		CreatedAt:      credential.CreatedAt,
+		LastUsedAt:     credential.LastUsedAt,
+	}
+}
+
+func ToTaskResponse(task *domain.Task) TaskResponse {
+	return TaskResponse{
+		ID:               task.ID,
+		Title:            task.Title,
+		Description:      task.Description,
+		Category:         task.Category,
+		Points:           task.Points,
+		VerificationMode: task.VerificationMode,
+		Archived:         task.Archived(),
+		CreatedAt:        task.CreatedAt,
+		UpdatedAt:        task.UpdatedAt,
+		ArchivedAt:       task.ArchivedAt,
+	}
+}
+
+func ToCatalogTaskResponse(task *domain.TaskWithStatus) CatalogTaskResponse {
+	response := CatalogTaskResponse{
+		ID:               task.ID,
+		Title:            task.Title,
+		Description:      task.Description,
+		Category:         task.Category,
+		Points:           task.Points,
+		VerificationMode: task.VerificationMode,
+		Completed:        task.Completed(),
+		CompletedAt:      task.CompletedAt,
+	}
+
+	if task.SubmissionStatus != nil {
+		response.SubmissionStatus = *task.SubmissionStatus
+	}
+
+	return response
+}
+
+// ToTaskSubmissionResponse expects the task of the submission to be loaded.
+func ToTaskSubmissionResponse(submission *domain.UserTask) TaskSubmissionResponse {
+	return TaskSubmissionResponse{
+		ID:              submission.ID,
+		TaskID:          submission.TaskID,
+		Title:           submission.Task.Title,
+		Points:          submission.Task.Points,
+		Status:          submission.Status,
+		ProofText:       submission.ProofText,
+		ProofURL:        submission.ProofURL,
+		ProofFileName:   submission.ProofFileName,
+		SubmittedAt:     submission.SubmittedAt,
+		CompletedAt:     submission.CompletedAt,
+		ReviewedAt:      submission.ReviewedAt,
+		RejectionReason: submission.RejectionReason,
+	}
+}
+
+// ToReviewSubmissionResponse expects the task and the user of the
+// submission to be loaded.
+func ToReviewSubmissionResponse(submission *domain.UserTask) ReviewSubmissionResponse {
+	return ReviewSubmissionResponse{
+		TaskSubmissionResponse: ToTaskSubmissionResponse(submission),
+		UserID:                 submission.UserID,
+		Username:               submission.User.Username,
+		VerificationMode:       submission.Task.VerificationMode,
+		ProofContentType:       submission.ProofContentType,
+		ReviewedBy:             submission.ReviewedBy,
+	}
+}
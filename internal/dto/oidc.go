@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type IdentityResponse struct {
+	Provider    string     `json:"provider"`
+	Email       *string    `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCCallbackResult is either a login, possibly waiting for the second
+// factor, or a provider account linked to the already signed in user.
+type OIDCCallbackResult struct {
+	Login  *LoginResult
+	Linked *IdentityResponse
+}
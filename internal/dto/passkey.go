@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"time"
+	"user-service/internal/webauthn"
+)
+
+// PasskeyCreationOptionsResponse is passed to navigator.credentials.create
+// as {publicKey: ...}.
+type PasskeyCreationOptionsResponse struct {
+	PublicKey webauthn.CreationOptions `json:"publicKey"`
+}
+
+type FinishPasskeyRegistrationRequest struct {
+	Name       string                          `json:"name" binding:"max=100"`
+	Credential webauthn.RegistrationCredential `json:"credential"`
+}
+
+// PasskeyRequestOptionsResponse is passed to navigator.credentials.get as
+// {publicKey: ...}.
+type PasskeyRequestOptionsResponse struct {
+	PublicKey webauthn.RequestOptions `json:"publicKey"`
+}
+
+type FinishPasskeyLoginRequest struct {
+	Credential webauthn.AssertionCredential `json:"credential"`
+	DeviceName string                       `json:"device_name"`
+}
+
+type PasskeyResponse struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackedUp       bool       `json:"backed_up"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,122 @@
+package dto
+
+import "time"
+
+// Points are capped at the range of the INTEGER column; the database itself
+// only requires them to be positive.
+// Categories are lowercase slugs; tasks without one are "general", and
+// tasks without a verification mode are completed automatically.
+type CreateTaskRequest struct {
+	Title            string `json:"title" binding:"required,max=255"`
+	Description      string `json:"description" binding:"max=10000"`
+	Category         string `json:"category" binding:"max=50"`
+	Points           int    `json:"points" binding:"required,gt=0,lte=2147483647"`
+	VerificationMode string `json:"verification_mode" binding:"omitempty,oneof=auto proof external"`
+}
+
+// UpdateTaskRequest changes only the fields that are present. A new
+// verification mode applies to later submissions.
+type UpdateTaskRequest struct {
+	Title            *string `json:"title" binding:"omitempty,max=255"`
+	Description      *string `json:"description" binding:"omitempty,max=10000"`
+	Category         *string `json:"category" binding:"omitempty,max=50"`
+	Points           *int    `json:"points" binding:"omitempty,gt=0,lte=2147483647"`
+	VerificationMode *string `json:"verification_mode" binding:"omitempty,oneof=auto proof external"`
+}
+
+type TaskResponse struct {
+	ID               int        `json:"id"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	Category         string     `json:"category"`
+	Points           int        `json:"points"`
+	VerificationMode string     `json:"verification_mode"`
+	Archived         bool       `json:"archived"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty"`
+}
+
+// TaskCatalogQuery filters GET /api/tasks. Status is all, completed, pending
+// or available; Cursor is the next_cursor of the previous page.
+type TaskCatalogQuery struct {
+	Category string `form:"category"`
+	Status   string `form:"status" binding:"omitempty,oneof=all completed pending available"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor   string `form:"cursor"`
+}
+
+type PageQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+// CatalogTaskResponse is a task as the user sees it. SubmissionStatus is the
+// status of the user's submission and is empty when there is none.
+type CatalogTaskResponse struct {
+	ID               int        `json:"id"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	Category         string     `json:"category"`
+	Points           int        `json:"points"`
+	VerificationMode string     `json:"verification_mode"`
+	Completed        bool       `json:"completed"`
+	SubmissionStatus string     `json:"submission_status,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
+
+// TaskCatalogResponse is one page of tasks. NextCursor is empty on the last
+// page.
+type TaskCatalogResponse struct {
+	Tasks      []CatalogTaskResponse `json:"tasks"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+type CompletedTasksResponse struct {
+	Tasks      []CompletedTaskResponse `json:"tasks"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// TaskSubmissionResponse is a submission of a task as its author sees it.
+type TaskSubmissionResponse struct {
+	ID              int        `json:"id"`
+	TaskID          int        `json:"task_id"`
+	Title           string     `json:"title"`
+	Points          int        `json:"points"`
+	Status          string     `json:"status"`
+	ProofText       string     `json:"proof_text,omitempty"`
+	ProofURL        string     `json:"proof_url,omitempty"`
+	ProofFileName   string     `json:"proof_file_name,omitempty"`
+	SubmittedAt     time.Time  `json:"submitted_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+}
+
+// ReviewSubmissionResponse is a submission as moderators see it. The proof
+// file is downloaded from /api/admin/task-submissions/{id}/proof.
+type ReviewSubmissionResponse struct {
+	TaskSubmissionResponse
+	UserID           int    `json:"user_id"`
+	Username         string `json:"username"`
+	VerificationMode string `json:"verification_mode"`
+	ProofContentType string `json:"proof_content_type,omitempty"`
+	ReviewedBy       *int   `json:"reviewed_by,omitempty"`
+}
+
+// SubmissionQuery filters GET /api/admin/task-submissions. Status defaults
+// to pending.
+type SubmissionQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+type TaskSubmissionsResponse struct {
+	Submissions []ReviewSubmissionResponse `json:"submissions"`
+	NextCursor  string                     `json:"next_cursor,omitempty"`
+}
+
+type RejectSubmissionRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
@@ -1,10 +1,17 @@
 package dto
 
+import "time"
+
 type UserStatusResponse struct {
-	ID         int    `json:"id"`
-	Username   string `json:"username"`
-	Balance    int    `json:"balance"`
-	ReferrerID *int   `json:"referrer_id,omitempty"`
+	ID            int     `json:"id"`
+	Username      string  `json:"username"`
+	Email         *string `json:"email,omitempty"`
+	EmailVerified bool    `json:"email_verified"`
+	Guest         bool    `json:"guest"`
+	Balance       int     `json:"balance"`
+	ReferrerID    *int    `json:"referrer_id,omitempty"`
+	// PurgeAfter is set while the account is pending deletion.
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
 }
 
 type LeaderboardUserDTO struct {
@@ -17,3 +24,33 @@ type LeaderboardUserDTO struct {
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
+
+// ValidationErrorResponse reports input problems per request field.
+type ValidationErrorResponse struct {
+	Error  string              `json:"error"`
+	Fields map[string][]string `json:"fields"`
+}
+
+type LockoutResponse struct {
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// CompleteTaskRequest submits a task. Tasks in the proof verification mode
+// need a text, a link or a file; the file comes as the proof_file part of a
+// multipart/form-data request.
+type CompleteTaskRequest struct {
+	TaskID    int    `json:"task_id" form:"task_id" binding:"required,min=1"`
+	ProofText string `json:"proof_text" form:"proof_text" binding:"max=5000"`
+	ProofURL  string `json:"proof_url" form:"proof_url" binding:"omitempty,http_url,max=2048"`
+}
+
+type AddReferrerRequest struct {
+	ReferrerID int `json:"referrer_id" binding:"required,min=1"`
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+	cookies        *Cookies
+}
+
+func NewAccountHandler(accountService *services.AccountService, cookies *Cookies) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		cookies:        cookies,
+	}
+}
+
+// ExportData godoc
+// @Summary      Выгрузка персональных данных
+// @Description  Возвращает все данные пользователя: профиль, историю баланса, выполненные задания, рефералов, сессии, привязанные аккаунты и API ключи. С format=zip отдается архив с отдельным JSON файлом на каждый раздел
+// @Tags         account
+// @Produce      json
+// @Produce      application/zip
+// @Param        format  query     string  false  "json или zip"  default(json)
+// @Security     BearerAuth
+// @Success      200  {object}  dto.AccountExport
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/users/me/export [get]
+func (h *AccountHandler) ExportData(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "format must be json or zip"})
+		return
+	}
+
+	export, err := h.accountService.Export(c.Request.Context(), userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("user-%d-export-%s.%s", userID, export.ExportedAt.Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+
+	if format == "json" {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := exportArchive(export)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// DeleteAccount godoc
+// @Summary      Удаление аккаунта
+// @Description  Помечает аккаунт на удаление: завершает все сессии, отзывает токены, API ключи перестают работать, аккаунт скрывается от других пользователей. Данные удаляются окончательно после ACCOUNT_DELETION_GRACE_PERIOD, до этого можно войти снова и отменить удаление через POST /api/users/me/restore. Аккаунт с паролем подтверждает его, аккаунт без пароля — кодом 2FA или входом не раньше чем за 10 минут до запроса
+// @Tags         account
+// @Accept       json
+// @Produce      json
+// @Param        request  body  dto.DeleteAccountRequest  false  "Текущий пароль или код 2FA"
+// @Security     BearerAuth
+// @Success      202  {object}  dto.AccountDeletionResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/users/me [delete]
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	sessionID, ok := middleware.GetSessionIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var req dto.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.accountService.Delete(c.Request.Context(), userID, sessionID, req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.cookies.ClearAuth(c)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// RestoreAccount godoc
+// @Summary      Отмена удаления аккаунта
+// @Description  Снимает пометку об удалении, пока не истек ACCOUNT_DELETION_GRACE_PERIOD. Аккаунт возвращается в прежнем виде
+// @Tags         account
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/users/me/restore [post]
+func (h *AccountHandler) RestoreAccount(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	if err := h.accountService.Restore(c.Request.Context(), userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "account restored")
+}
+
+func (h *AccountHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrWrongPassword),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrReauthenticationRequired):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrDeletionScheduled), errors.Is(err, services.ErrDeletionNotScheduled):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
+
+// exportArchive packs every section of the export into its own JSON file.
+func exportArchive(export *dto.AccountExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"balance_history.json", export.BalanceHistory},
+		{"completed_tasks.json", export.CompletedTasks},
+		{"referrals.json", export.Referrals},
+		{"sessions.json", export.Sessions},
+		{"identities.json", export.Identities},
+		{"api_keys.json", export.APIKeys},
+	}
+
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/internal/authz"
+	"user-service/internal/dto"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+}
+
+func NewAdminHandler(adminService *services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// ListLockouts godoc
+// @Summary      Заблокированные входы
+// @Description  Возвращает имена пользователей и IP адреса, для которых вход временно заблокирован после неудачных попыток
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   dto.LockoutResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/admin/lockouts [get]
+func (h *AdminHandler) ListLockouts(c *gin.Context) {
+	if !authorize(c, authz.ActionManageLockouts, authz.None()) {
+		return
+	}
+
+	response, err := h.adminService.ListLockouts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Unlock godoc
+// @Summary      Снять блокировку входа
+// @Description  Сбрасывает счетчик неудачных попыток для ключа вида user:<username> или ip:<адрес>
+// @Tags         admin
+// @Produce      json
+// @Param        key  path      string  true  "Ключ блокировки"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/admin/lockouts/{key} [delete]
+func (h *AdminHandler) Unlock(c *gin.Context) {
+	if !authorize(c, authz.ActionManageLockouts, authz.None()) {
+		return
+	}
+
+	if err := h.adminService.Unlock(c.Request.Context(), c.Param("key")); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, "lockout removed")
+}
+
+// BanUser godoc
+// @Summary      Заблокировать пользователя
+// @Description  Запрещает пользователю входить и отзывает все его сессии, токены и API ключи. Можно блокировать только пользователей с ролью ниже своей
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/users/{id}/ban [post]
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	actor, userID, ok := h.actorAndTarget(c, authz.ActionBanUser)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.BanUser(c.Request.Context(), actor, userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "user banned")
+}
+
+// UnbanUser godoc
+// @Summary      Разблокировать пользователя
+// @Description  Снимает блокировку, после чего пользователь снова может войти
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/users/{id}/ban [delete]
+func (h *AdminHandler) UnbanUser(c *gin.Context) {
+	actor, userID, ok := h.actorAndTarget(c, authz.ActionBanUser)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.UnbanUser(c.Request.Context(), actor, userID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "user unbanned")
+}
+
+// ChangeRole godoc
+// @Summary      Изменить роль пользователя
+// @Description  Назначает роль user, moderator или admin пользователю с ролью ниже своей. Выданные ранее access токены пользователя отзываются
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                    true  "User ID"
+// @Param        request  body  dto.ChangeRoleRequest  true  "Новая роль"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/users/{id}/role [put]
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	actor, userID, ok := h.actorAndTarget(c, authz.ActionChangeRole)
+	if !ok {
+		return
+	}
+
+	var req dto.ChangeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.adminService.ChangeRole(c.Request.Context(), actor, userID, req.Role); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "role changed")
+}
+
+// Impersonate godoc
+// @Summary      Войти от имени пользователя
+// @Description  Выдает администратору короткоживущий access токен пользователя с ролью ниже своей. В токене есть claim act с администратором, по умолчанию токен только для чтения. Управлять аккаунтом и открывать /api/admin с ним нельзя. Каждый вход записывается в журнал
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                     true  "User ID"
+// @Param        request  body  dto.ImpersonateRequest  true  "Причина и режим доступа"
+// @Security     BearerAuth
+// @Success      201  {object}  dto.ImpersonationTokenResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/users/{id}/impersonate [post]
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	actor, userID, ok := h.actorAndTarget(c, authz.ActionImpersonate)
+	if !ok {
+		return
+	}
+
+	var req dto.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.adminService.Impersonate(c.Request.Context(), actor, userID, req, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListImpersonations godoc
+// @Summary      Журнал входов от имени пользователей
+// @Description  Возвращает последние 100 сессий имперсонации: кто, от чьего имени, зачем и когда
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   dto.ImpersonationSessionResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/admin/impersonations [get]
+func (h *AdminHandler) ListImpersonations(c *gin.Context) {
+	if !authorize(c, authz.ActionImpersonate, authz.None()) {
+		return
+	}
+
+	response, err := h.adminService.ListImpersonations(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EndImpersonation godoc
+// @Summary      Завершить вход от имени пользователя
+// @Description  Досрочно отзывает токен сессии имперсонации
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Impersonation session ID"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/impersonations/{id} [delete]
+func (h *AdminHandler) EndImpersonation(c *gin.Context) {
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid ID"})
+		return
+	}
+
+	if !authorize(c, authz.ActionImpersonate, authz.None()) {
+		return
+	}
+
+	subject, _ := authz.SubjectFromContext(c.Request.Context())
+	actor := services.Actor{UserID: subject.UserID, Role: subject.Role}
+
+	if err := h.adminService.EndImpersonation(c.Request.Context(), actor, sessionID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "impersonation ended")
+}
+
+// actorAndTarget authorizes an action on the user from the path and returns
+// the caller together with the target user id.
+func (h *AdminHandler) actorAndTarget(c *gin.Context, action authz.Action) (services.Actor, int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid ID"})
+		return services.Actor{}, 0, false
+	}
+
+	if !authorize(c, action, authz.User(userID)) {
+		return services.Actor{}, 0, false
+	}
+
+	subject, _ := authz.SubjectFromContext(c.Request.Context())
+
+	return services.Actor{UserID: subject.UserID, Role: subject.Role}, userID, true
+}
+
+func (h *AdminHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrImpersonationEnded):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrCannotModifySelf):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInsufficientRank):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary      Создать API ключ
+// @Description  Выпускает долгоживущий ключ с ограниченными правами для скриптов и других сервисов. Ключ передается в заголовке X-API-Key или Authorization: Bearer и показывается только один раз
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateAPIKeyRequest true "Название, scopes (users:read, users:write) и срок жизни"
+// @Security     BearerAuth
+// @Success      201  {object}  dto.CreatedAPIKeyResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.apiKeyService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownScope):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAPIKeys):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys godoc
+// @Summary      Список API ключей
+// @Description  Возвращает активные API ключи пользователя с префиксом, scopes и временем последнего использования. Сами ключи не возвращаются
+// @Tags         api-keys
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   dto.APIKeyResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Router       /api/api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	response, err := h.apiKeyService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Отозвать API ключ
+// @Description  Отзывает API ключ, запросы с ним сразу перестают проходить
+// @Tags         api-keys
+// @Produce      json
+// @Param        id   path      int  true  "API key ID"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid API key id"})
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(c.Request.Context(), userID, keyID); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, "API key revoked")
+}
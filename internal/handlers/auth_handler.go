@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"user-service/internal/dto"
 	"user-service/internal/middleware"
 	"user-service/internal/services"
@@ -11,23 +14,26 @@ import (
 
 type AuthHandler struct {
 	authService *services.AuthService
+	cookies     *Cookies
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, cookies *Cookies) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		cookies:     cookies,
 	}
 }
 
 // Register godoc
 // @Summary      Регистрация нового пользователя
-// @Description  Создает нового пользователя с username и password
+// @Description  Создает нового пользователя с username и password. Username приводится к нижнему регистру и NFKC, допускаются латинские буквы, цифры, '.', '_' и '-', длина от 3 до 32 символов. Если указан email, на него отправляется ссылка для подтверждения
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body dto.RegisterRequest true "Данные для регистрации"
 // @Success      201  {object}  map[string]interface{}  "Успешная регистрация"
-// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      400  {object}  dto.ValidationErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
 // @Router       /register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req dto.RegisterRequest
@@ -39,6 +45,20 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(c.Request.Context(), req)
 	if err != nil {
+		if respondUsernameError(c, err) || respondPasswordPolicyError(c, "password", err) {
+			return
+		}
+		if errors.Is(err, services.ErrEmailRequired) {
+			c.JSON(http.StatusBadRequest, dto.ValidationErrorResponse{
+				Error:  err.Error(),
+				Fields: map[string][]string{"email": {err.Error()}},
+			})
+			return
+		}
+		if errors.Is(err, services.ErrUsernameTaken) || errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -54,13 +74,16 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 // Login godoc
 // @Summary      Вход пользователя
-// @Description  Аутентификация пользователя и выдача JWT токенов
+// @Description  Аутентификация пользователя и выдача JWT токенов. Если у пользователя включена двухфакторная аутентификация, вместо токенов возвращается challenge_token для /login/2fa
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body dto.LoginRequest true "Данные для входа"
 // @Success      200  {object}  dto.TokenResponse
+// @Success      202  {object}  dto.TwoFactorChallengeResponse
 // @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      429  {object}  dto.ErrorResponse
 // @Router       /login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
@@ -70,21 +93,99 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.authService.Login(c.Request.Context(), req)
+	result, err := h.authService.Login(c.Request.Context(), req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+		h.respondLoginError(c, err)
+		return
+	}
+
+	if result.Challenge != nil {
+		c.JSON(http.StatusAccepted, result.Challenge)
+		return
+	}
+
+	h.cookies.SetAccessToken(c, result.Tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, result.Tokens.RefreshToken)
+
+	c.JSON(http.StatusOK, result.Tokens)
+}
+
+// LoginTwoFactor godoc
+// @Summary      Второй шаг входа
+// @Description  Обменивает challenge_token из /login и код из приложения-аутентификатора (или одноразовый код восстановления) на JWT токены
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.TwoFactorLoginRequest true "Challenge токен и код"
+// @Success      200  {object}  dto.TokenResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      429  {object}  dto.ErrorResponse
+// @Router       /login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tokens, err := h.authService.LoginTwoFactor(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		h.respondLoginError(c, err)
 		return
 	}
 
-	h.setAccessTokenCookie(c, tokens.AccessToken)
-	h.setRefreshTokenCookie(c, tokens.RefreshToken)
+	h.cookies.SetAccessToken(c, tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, tokens.RefreshToken)
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh godoc
+// @Summary      Обновление токенов
+// @Description  Выдает новую пару токенов по refresh токену из cookie refresh_token или тела запроса. Каждый refresh токен одноразовый, повторное использование отзывает все токены этого входа
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.RefreshRequest false "Refresh токен, если он не передан в cookie"
+// @Success      200  {object}  dto.TokenResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Router       /refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	refreshToken, err := c.Cookie("refresh_token")
+
+	if err != nil || refreshToken == "" {
+		var req dto.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "refresh token is missing"})
+			return
+		}
+		refreshToken = req.RefreshToken
+	}
+
+	tokens, err := h.authService.RefreshAccessToken(c.Request.Context(), refreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			h.cookies.ClearAuth(c)
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.cookies.SetAccessToken(c, tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, tokens.RefreshToken)
 
 	c.JSON(http.StatusOK, tokens)
 }
 
 // Logout godoc
 // @Summary      Выход пользователя
-// @Description  Отзыв refresh токена пользователя
+// @Description  Завершает текущую сессию пользователя и отзывает ее refresh токен
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -93,32 +194,112 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Failure      401  {object}  dto.ErrorResponse
 // @Router       /api/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	userID, ok := middleware.GetUserIDFromContext(c)
+	claims, ok := middleware.GetClaimsFromContext(c)
 	if !ok {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
 		return
 	}
 
-	if err := h.authService.Logout(c.Request.Context(), userID); err != nil {
+	if err := h.authService.Logout(c.Request.Context(), claims); err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	h.clearAuthCookies(c)
+	h.cookies.ClearAuth(c)
 	c.JSON(http.StatusOK, "successfully logged out")
 }
 
-func (h *AuthHandler) setAccessTokenCookie(c *gin.Context, token string) {
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("access_token", token, 15*60, "/", "", false, true)
+// LogoutAll godoc
+// @Summary      Выход со всех устройств
+// @Description  Завершает все сессии пользователя и отзывает все его refresh токены
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      401  {object}  dto.ErrorResponse
+// @Router       /api/logout/all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	if err := h.authService.LogoutAll(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.cookies.ClearAuth(c)
+	c.JSON(http.StatusOK, "successfully logged out from all devices")
+}
+
+// ChangePassword godoc
+// @Summary      Смена пароля
+// @Description  Меняет пароль пользователя и завершает все его сессии, после чего нужно войти заново
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.ChangePasswordRequest true "Текущий и новый пароль"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ValidationErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Router       /api/password/change [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.authService.ChangePassword(c.Request.Context(), userID, req); err != nil {
+		if respondPasswordPolicyError(c, "new_password", err) {
+			return
+		}
+		if errors.Is(err, services.ErrWrongPassword) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.cookies.ClearAuth(c)
+	c.JSON(http.StatusOK, "password changed, please log in again")
 }
 
-func (h *AuthHandler) setRefreshTokenCookie(c *gin.Context, token string) {
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("refresh_token", token, 7*24*60*60, "/", "", false, true)
+func (h *AuthHandler) respondLoginError(c *gin.Context, err error) {
+	var lockout *services.LockoutError
+	if errors.As(err, &lockout) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if errors.Is(err, services.ErrAccountBanned) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if errors.Is(err, services.ErrInvalidCredentials) || errors.Is(err, services.ErrInvalidTwoFactorCode) {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "login failed"})
 }
 
-func (h *AuthHandler) clearAuthCookies(c *gin.Context) {
-	c.SetCookie("access_token", "", -1, "/", "", false, true)
-	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
 }
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/authz"
+	"user-service/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkAccess is authz.Authorize; tests replace it to see which action and
+// resource each handler asks about.
+var checkAccess = authz.Authorize
+
+// authorize runs authz.Authorize for the request and answers it when the
+// action is not allowed. It reports whether the handler may go on.
+func authorize(c *gin.Context, action authz.Action, resource authz.Resource) bool {
+	err := checkAccess(c.Request.Context(), action, resource)
+	if err == nil {
+		return true
+	}
+
+	if errors.Is(err, authz.ErrUnauthenticated) {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+	} else {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	}
+
+	return false
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-service/internal/authz"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callerID is the signed in user of every request; targetID is the user in
+// the path.
+const (
+	callerID = 7
+	targetID = 5
+)
+
+type authzCall struct {
+	action   authz.Action
+	resource authz.Resource
+}
+
+// TestRoutesAuthorize checks that each protected route asks for the right
+// action on the right resource before doing anything else. The handlers have
+// no services, so a route that skipped the check would panic.
+func TestRoutesAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls []authzCall
+	checkAccess = func(ctx context.Context, action authz.Action, resource authz.Resource) error {
+		calls = append(calls, authzCall{action, resource})
+		return authz.ErrForbidden
+	}
+	t.Cleanup(func() { checkAccess = authz.Authorize })
+
+	userHandler := &UserHandler{}
+	taskHandler := &TaskHandler{}
+	adminHandler := &AdminHandler{}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", callerID)
+	})
+
+	// The same routes as cmd/app/main.go.
+	api := router.Group("/api")
+	api.GET("/users/:id/status", userHandler.GetStatus)
+	api.GET("/users/leaderboard", userHandler.GetLeaderBoard)
+	api.GET("/users/me/tasks", userHandler.GetCompletedTasks)
+	api.GET("/tasks", taskHandler.ListTasks)
+	api.POST("/users/:id/task/complete", userHandler.CompleteTask)
+	api.POST("/users/:id/referrer", userHandler.AddReferrer)
+
+	admin := api.Group("/admin")
+	admin.GET("/lockouts", adminHandler.ListLockouts)
+	admin.DELETE("/lockouts/:key", adminHandler.Unlock)
+	admin.POST("/users/:id/ban", adminHandler.BanUser)
+	admin.DELETE("/users/:id/ban", adminHandler.UnbanUser)
+	admin.PUT("/users/:id/role", adminHandler.ChangeRole)
+	admin.POST("/users/:id/impersonate", adminHandler.Impersonate)
+	admin.GET("/impersonations", adminHandler.ListImpersonations)
+	admin.DELETE("/impersonations/:id", adminHandler.EndImpersonation)
+	admin.GET("/tasks", taskHandler.AdminListTasks)
+	admin.POST("/tasks", taskHandler.CreateTask)
+	admin.PATCH("/tasks/:id", taskHandler.UpdateTask)
+	admin.POST("/tasks/:id/archive", taskHandler.ArchiveTask)
+	admin.GET("/task-submissions", taskHandler.ListSubmissions)
+	admin.GET("/task-submissions/:id/proof", taskHandler.DownloadProof)
+	admin.POST("/task-submissions/:id/approve", taskHandler.ApproveSubmission)
+	admin.POST("/task-submissions/:id/reject", taskHandler.RejectSubmission)
+
+	tests := []struct {
+		method   string
+		path     string
+		action   authz.Action
+		resource authz.Resource
+	}{
+		{http.MethodGet, "/api/users/5/status", authz.ActionViewUserStatus, authz.User(targetID)},
+		{http.MethodGet, "/api/users/leaderboard", authz.ActionViewLeaderboard, authz.None()},
+		{http.MethodGet, "/api/users/me/tasks", authz.ActionViewTaskHistory, authz.User(callerID)},
+		{http.MethodGet, "/api/tasks", authz.ActionViewTasks, authz.None()},
+		{http.MethodPost, "/api/users/5/task/complete", authz.ActionCompleteTask, authz.User(targetID)},
+		{http.MethodPost, "/api/users/5/referrer", authz.ActionSetReferrer, authz.User(targetID)},
+		{http.MethodGet, "/api/admin/lockouts", authz.ActionManageLockouts, authz.None()},
+		{http.MethodDelete, "/api/admin/lockouts/ip:127.0.0.1", authz.ActionManageLockouts, authz.None()},
+		{http.MethodPost, "/api/admin/users/5/ban", authz.ActionBanUser, authz.User(targetID)},
+		{http.MethodDelete, "/api/admin/users/5/ban", authz.ActionBanUser, authz.User(targetID)},
+		{http.MethodPut, "/api/admin/users/5/role", authz.ActionChangeRole, authz.User(targetID)},
+		{http.MethodPost, "/api/admin/users/5/impersonate", authz.ActionImpersonate, authz.User(targetID)},
+		{http.MethodGet, "/api/admin/impersonations", authz.ActionImpersonate, authz.None()},
+		{http.MethodDelete, "/api/admin/impersonations/3", authz.ActionImpersonate, authz.None()},
+		{http.MethodGet, "/api/admin/tasks", authz.ActionManageTasks, authz.None()},
+		{http.MethodPost, "/api/admin/tasks", authz.ActionManageTasks, authz.None()},
+		{http.MethodPatch, "/api/admin/tasks/3", authz.ActionManageTasks, authz.None()},
+		{http.MethodPost, "/api/admin/tasks/3/archive", authz.ActionManageTasks, authz.None()},
+		{http.MethodGet, "/api/admin/task-submissions", authz.ActionModerateTasks, authz.None()},
+		{http.MethodGet, "/api/admin/task-submissions/3/proof", authz.ActionModerateTasks, authz.None()},
+		{http.MethodPost, "/api/admin/task-submissions/3/approve", authz.ActionModerateTasks, authz.None()},
+		{http.MethodPost, "/api/admin/task-submissions/3/reject", authz.ActionModerateTasks, authz.None()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			calls = nil
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusForbidden)
+			}
+
+			want := authzCall{tt.action, tt.resource}
+			if len(calls) != 1 || calls[0] != want {
+				t.Fatalf("got authorize calls %+v, want [%+v]", calls, want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		subject *authz.Subject
+		status  int
+	}{
+		{"no subject", nil, http.StatusUnauthorized},
+		{"denied", &authz.Subject{UserID: callerID}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.subject != nil {
+				c.Request = c.Request.WithContext(authz.WithSubject(c.Request.Context(), *tt.subject))
+			}
+
+			if authorize(c, authz.ActionManageTasks, authz.None()) {
+				t.Fatal("authorize allowed the request")
+			}
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"net/http"
+	"user-service/internal/config"
+	"user-service/internal/csrf"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	accessTokenCookieMaxAge  = 15 * 60
+	refreshTokenCookieMaxAge = 7 * 24 * 60 * 60
+
+	magicLinkBindingCookie = "magic_link_binding"
+)
+
+// Cookies writes the cookies of the service with the attributes from the
+// config.
+type Cookies struct {
+	secure   bool
+	domain   string
+	sameSite http.SameSite
+}
+
+func NewCookies(cfg config.CookieConfig) *Cookies {
+	sameSite := http.SameSiteLaxMode
+	switch cfg.SameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return &Cookies{
+		secure:   cfg.Secure,
+		domain:   cfg.Domain,
+		sameSite: sameSite,
+	}
+}
+
+// SetAccessToken also sets the csrf_token cookie that goes with the token.
+// Unlike the token it is readable by scripts, which send it back in the
+// X-CSRF-Token header.
+func (ck *Cookies) SetAccessToken(c *gin.Context, token string) {
+	ck.set(c, ck.sameSite, "access_token", token, accessTokenCookieMaxAge, "/", true)
+	ck.set(c, ck.sameSite, csrf.CookieName, csrf.Token(token), accessTokenCookieMaxAge, "/", false)
+}
+
+func (ck *Cookies) SetRefreshToken(c *gin.Context, token string) {
+	ck.set(c, ck.sameSite, "refresh_token", token, refreshTokenCookieMaxAge, "/", true)
+}
+
+func (ck *Cookies) ClearAuth(c *gin.Context) {
+	ck.set(c, ck.sameSite, "access_token", "", -1, "/", true)
+	ck.set(c, ck.sameSite, "refresh_token", "", -1, "/", true)
+	ck.set(c, ck.sameSite, csrf.CookieName, "", -1, "/", false)
+}
+
+// SetOIDCState binds an authorization request to the browser that started
+// it, so a callback URL sent to someone else cannot sign them in. The cookie
+// has to come back on the top-level redirect from the provider, which Strict
+// cookies do not, so it is never stricter than Lax.
+func (ck *Cookies) SetOIDCState(c *gin.Context, state string, maxAge int) {
+	ck.set(c, ck.oidcSameSite(), "oidc_state", state, maxAge, "/oidc", true)
+}
+
+func (ck *Cookies) ClearOIDCState(c *gin.Context) {
+	ck.set(c, ck.oidcSameSite(), "oidc_state", "", -1, "/oidc", true)
+}
+
+// SetMagicLinkBinding keeps the binding secret of a requested sign-in link in
+// the browser that asked for it, so a forwarded link does not work elsewhere.
+func (ck *Cookies) SetMagicLinkBinding(c *gin.Context, binding string, maxAge int) {
+	ck.set(c, ck.sameSite, magicLinkBindingCookie, binding, maxAge, "/login/magic-link", true)
+}
+
+func (ck *Cookies) ClearMagicLinkBinding(c *gin.Context) {
+	ck.set(c, ck.sameSite, magicLinkBindingCookie, "", -1, "/login/magic-link", true)
+}
+
+func (ck *Cookies) oidcSameSite() http.SameSite {
+	if ck.sameSite == http.SameSiteStrictMode {
+		return http.SameSiteLaxMode
+	}
+	return ck.sameSite
+}
+
+func (ck *Cookies) set(c *gin.Context, sameSite http.SameSite, name, value string, maxAge int, path string, httpOnly bool) {
+	c.SetSameSite(sameSite)
+	c.SetCookie(name, value, maxAge, path, ck.domain, ck.secure, httpOnly)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailHandler struct {
+	emailService *services.EmailVerificationService
+}
+
+func NewEmailHandler(emailService *services.EmailVerificationService) *EmailHandler {
+	return &EmailHandler{
+		emailService: emailService,
+	}
+}
+
+// VerifyEmail godoc
+// @Summary      Подтверждение email
+// @Description  Подтверждает адрес пользователя по одноразовому токену из письма. Ссылка на адрес, который пользователь уже сменил, не действует
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.VerifyEmailRequest true "Токен из письма"
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Router       /verify-email [post]
+func (h *EmailHandler) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.emailService.Verify(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, "email verified")
+}
+
+// ChangeEmail godoc
+// @Summary      Смена email
+// @Description  Устанавливает новый адрес и отправляет на него ссылку для подтверждения. До подтверждения адрес считается неподтвержденным. Повторная отправка текущего неподтвержденного адреса высылает новую ссылку
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.ChangeEmailRequest true "Новый адрес"
+// @Security     BearerAuth
+// @Success      202  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/email [put]
+func (h *EmailHandler) ChangeEmail(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var req dto.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.emailService.ChangeEmail(c.Request.Context(), userID, req.Email); err != nil {
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, "verification link sent")
+}
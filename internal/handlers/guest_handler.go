@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GuestHandler struct {
+	guestService *services.GuestService
+	cookies      *Cookies
+}
+
+func NewGuestHandler(guestService *services.GuestService, cookies *Cookies) *GuestHandler {
+	return &GuestHandler{
+		guestService: guestService,
+		cookies:      cookies,
+	}
+}
+
+// CreateGuest godoc
+// @Summary      Гостевой аккаунт
+// @Description  Создает аккаунт без имени и пароля и выдает JWT токены. device_secret показывается один раз, по нему гость входит снова через /guest/login. Гость может выполнять задания, но не указывать реферера и не попадает в рейтинг. С одного IP за GUEST_RATE_WINDOW можно создать не больше GUEST_MAX_PER_IP гостей
+// @Tags         guest
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateGuestRequest false "Название устройства"
+// @Success      201  {object}  dto.GuestResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      429  {object}  dto.ErrorResponse
+// @Router       /guest [post]
+func (h *GuestHandler) CreateGuest(c *gin.Context) {
+	var req dto.CreateGuestRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.guestService.CreateGuest(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrGuestRateLimited) {
+			c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.cookies.SetAccessToken(c, response.AccessToken)
+	h.cookies.SetRefreshToken(c, response.RefreshToken)
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// LoginGuest godoc
+// @Summary      Вход гостя
+// @Description  Выдает JWT токены гостевому аккаунту по device_secret, полученному при его создании
+// @Tags         guest
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.GuestLoginRequest true "Секрет устройства"
+// @Success      200  {object}  dto.TokenResponse
+// @Success      202  {object}  dto.TwoFactorChallengeResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /guest/login [post]
+func (h *GuestHandler) LoginGuest(c *gin.Context) {
+	var req dto.GuestLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := h.guestService.Login(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+// UpgradeGuest godoc
+// @Summary      Превратить гостя в полноценный аккаунт
+// @Description  Задает гостю username, пароль и, при желании, email по тем же правилам, что и регистрация. id, баланс и выполненные задания сохраняются. device_secret перестает действовать, текущая сессия завершается и выдаются новые токены
+// @Tags         guest
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.UpgradeGuestRequest true "Данные аккаунта"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.TokenResponse
+// @Failure      400  {object}  dto.ValidationErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/guest/upgrade [post]
+func (h *GuestHandler) UpgradeGuest(c *gin.Context) {
+	claims, ok := middleware.GetClaimsFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var req dto.UpgradeGuestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	result, err := h.guestService.Upgrade(c.Request.Context(), claims, req, clientInfo(c))
+	if err != nil {
+		if respondUsernameError(c, err) || respondPasswordPolicyError(c, "password", err) {
+			return
+		}
+		if errors.Is(err, services.ErrEmailRequired) {
+			c.JSON(http.StatusBadRequest, dto.ValidationErrorResponse{
+				Error:  err.Error(),
+				Fields: map[string][]string{"email": {err.Error()}},
+			})
+			return
+		}
+		h.respondError(c, err)
+		return
+	}
+
+	h.respondLogin(c, result)
+}
+
+func (h *GuestHandler) respondLogin(c *gin.Context, result *dto.LoginResult) {
+	if result.Challenge != nil {
+		h.cookies.ClearAuth(c)
+		c.JSON(http.StatusAccepted, result.Challenge)
+		return
+	}
+
+	h.cookies.SetAccessToken(c, result.Tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, result.Tokens.RefreshToken)
+
+	c.JSON(http.StatusOK, result.Tokens)
+}
+
+func (h *GuestHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidGuestCredential), errors.Is(err, services.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrAccountBanned):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrUsernameTaken), errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrNotGuest):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	jwtService *services.JWTService
+}
+
+func NewJWKSHandler(jwtService *services.JWTService) *JWKSHandler {
+	return &JWKSHandler{
+		jwtService: jwtService,
+	}
+}
+
+// JWKS godoc
+// @Summary      Публичные ключи подписи токенов
+// @Description  Возвращает JWK Set с публичными ключами, которыми можно проверить выданные сервисом JWT
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  dto.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkHandler struct {
+	magicLinkService *services.MagicLinkService
+	cookies          *Cookies
+}
+
+func NewMagicLinkHandler(magicLinkService *services.MagicLinkService, cookies *Cookies) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		magicLinkService: magicLinkService,
+		cookies:          cookies,
+	}
+}
+
+// RequestMagicLink godoc
+// @Summary      Запрос ссылки для входа без пароля
+// @Description  Отправляет одноразовую ссылку для входа, если адрес подтвержден у аккаунта. Ссылка работает только на устройстве, которое ее запросило: браузер получает cookie magic_link_binding, остальные клиенты — device_binding в ответе. Ответ одинаковый независимо от того, существует ли аккаунт
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.MagicLinkRequest true "Email аккаунта"
+// @Success      202  {object}  dto.MagicLinkRequestedResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      429  {object}  dto.ErrorResponse
+// @Router       /login/magic-link [post]
+func (h *MagicLinkHandler) RequestMagicLink(c *gin.Context) {
+	var req dto.MagicLinkRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	binding, err := h.magicLinkService.RequestLink(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrMagicLinkRateLimited) {
+			c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "failed to process sign-in link request"})
+		return
+	}
+
+	h.cookies.SetMagicLinkBinding(c, binding, int(h.magicLinkService.TokenTTL().Seconds()))
+
+	c.JSON(http.StatusAccepted, dto.MagicLinkRequestedResponse{
+		Message:       "if the account exists, a sign-in link was sent",
+		DeviceBinding: binding,
+	})
+}
+
+// LoginMagicLink godoc
+// @Summary      Вход по ссылке из письма
+// @Description  Обменивает токен из ссылки на JWT токены так же, как /login. device_binding берется из тела или из cookie magic_link_binding. Если у пользователя включена двухфакторная аутентификация, возвращается challenge_token для /login/2fa
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.MagicLinkLoginRequest true "Токен из ссылки"
+// @Success      200  {object}  dto.TokenResponse
+// @Success      202  {object}  dto.TwoFactorChallengeResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /login/magic-link/verify [post]
+func (h *MagicLinkHandler) LoginMagicLink(c *gin.Context) {
+	var req dto.MagicLinkLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.DeviceBinding == "" {
+		req.DeviceBinding, _ = c.Cookie(magicLinkBindingCookie)
+	}
+
+	result, err := h.magicLinkService.Login(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMagicLink), errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrAccountBanned):
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "login failed"})
+		}
+		return
+	}
+
+	h.cookies.ClearMagicLinkBinding(c)
+
+	if result.Challenge != nil {
+		c.JSON(http.StatusAccepted, result.Challenge)
+		return
+	}
+
+	h.cookies.SetAccessToken(c, result.Tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, result.Tokens.RefreshToken)
+
+	c.JSON(http.StatusOK, result.Tokens)
+}
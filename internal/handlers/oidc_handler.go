@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	cookies     *Cookies
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService, cookies *Cookies) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+		cookies:     cookies,
+	}
+}
+
+// Providers godoc
+// @Summary      Внешние провайдеры входа
+// @Description  Возвращает имена настроенных OpenID Connect провайдеров, через которые можно войти
+// @Tags         oidc
+// @Produce      json
+// @Success      200  {array}   string
+// @Router       /oidc/providers [get]
+func (h *OIDCHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, h.oidcService.Providers())
+}
+
+// Login godoc
+// @Summary      Вход через внешнего провайдера
+// @Description  Перенаправляет браузер на страницу входа провайдера (authorization code flow с PKCE). После входа провайдер вернет пользователя на /oidc/{provider}/callback
+// @Tags         oidc
+// @Param        provider  path  string  true  "Имя провайдера"
+// @Success      302
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      502  {object}  dto.ErrorResponse
+// @Router       /oidc/{provider}/login [get]
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, state, err := h.oidcService.StartLogin(c.Request.Context(), c.Param("provider"), nil)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.cookies.SetOIDCState(c, state, int(h.oidcService.StateTTL().Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Link godoc
+// @Summary      Привязка внешнего аккаунта
+// @Description  Начинает привязку аккаунта провайдера к текущему пользователю. Браузер нужно перенаправить на authorization_url, привязка завершится на /oidc/{provider}/callback
+// @Tags         oidc
+// @Produce      json
+// @Param        provider  path  string  true  "Имя провайдера"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.OIDCAuthorizationResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/oidc/{provider}/link [post]
+func (h *OIDCHandler) Link(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	authURL, state, err := h.oidcService.StartLogin(c.Request.Context(), c.Param("provider"), &userID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.cookies.SetOIDCState(c, state, int(h.oidcService.StateTTL().Seconds()))
+	c.JSON(http.StatusOK, dto.OIDCAuthorizationResponse{AuthorizationURL: authURL})
+}
+
+// Callback godoc
+// @Summary      Возврат от внешнего провайдера
+// @Description  Обменивает код авторизации на токены сервиса. Если у пользователя включена двухфакторная аутентификация, возвращается challenge_token для /login/2fa. Для запросов привязки возвращает привязанный аккаунт
+// @Tags         oidc
+// @Produce      json
+// @Param        provider  path   string  true  "Имя провайдера"
+// @Param        code      query  string  true  "Код авторизации"
+// @Param        state     query  string  true  "State из запроса авторизации"
+// @Success      200  {object}  dto.TokenResponse
+// @Success      202  {object}  dto.TwoFactorChallengeResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      502  {object}  dto.ErrorResponse
+// @Router       /oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		h.cookies.ClearOIDCState(c)
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "identity provider returned " + providerError})
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	cookieState, err := c.Cookie("oidc_state")
+	h.cookies.ClearOIDCState(c)
+
+	if err != nil || state == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: services.ErrInvalidOIDCState.Error()})
+		return
+	}
+
+	result, err := h.oidcService.Callback(c.Request.Context(), c.Param("provider"), state, code, clientInfo(c))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	if result.Linked != nil {
+		c.JSON(http.StatusOK, result.Linked)
+		return
+	}
+
+	if result.Login.Challenge != nil {
+		c.JSON(http.StatusAccepted, result.Login.Challenge)
+		return
+	}
+
+	h.cookies.SetAccessToken(c, result.Login.Tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, result.Login.Tokens.RefreshToken)
+
+	c.JSON(http.StatusOK, result.Login.Tokens)
+}
+
+// ListIdentities godoc
+// @Summary      Привязанные внешние аккаунты
+// @Description  Возвращает аккаунты внешних провайдеров, через которые можно войти как текущий пользователь
+// @Tags         oidc
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   dto.IdentityResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Router       /api/identities [get]
+func (h *OIDCHandler) ListIdentities(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	response, err := h.oidcService.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OIDCHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidOIDCState):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrAccountBanned):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrIdentityAlreadyLinked):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrOIDCLoginFailed):
+		c.JSON(http.StatusBadGateway, dto.ErrorResponse{Error: services.ErrOIDCLoginFailed.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "login failed"})
+	}
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyHandler struct {
+	passkeyService *services.PasskeyService
+	cookies        *Cookies
+}
+
+func NewPasskeyHandler(passkeyService *services.PasskeyService, cookies *Cookies) *PasskeyHandler {
+	return &PasskeyHandler{
+		passkeyService: passkeyService,
+		cookies:        cookies,
+	}
+}
+
+// BeginRegistration godoc
+// @Summary      Начать регистрацию passkey
+// @Description  Возвращает параметры для navigator.credentials.create({publicKey}). Двоичные поля закодированы в base64url. Ответ браузера нужно передать в /api/passkeys/register/finish в течение WEBAUTHN_TIMEOUT
+// @Tags         passkeys
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  dto.PasskeyCreationOptionsResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/passkeys/register/begin [post]
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	response, err := h.passkeyService.BeginRegistration(c.Request.Context(), userID)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// FinishRegistration godoc
+// @Summary      Завершить регистрацию passkey
+// @Description  Проверяет ответ аутентификатора и сохраняет passkey под указанным названием
+// @Tags         passkeys
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.FinishPasskeyRegistrationRequest true "Название и ответ navigator.credentials.create"
+// @Security     BearerAuth
+// @Success      201  {object}  dto.PasskeyResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/passkeys/register/finish [post]
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var req dto.FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.passkeyService.FinishRegistration(c.Request.Context(), userID, req)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListPasskeys godoc
+// @Summary      Список passkeys
+// @Description  Возвращает зарегистрированные passkeys пользователя с названием и временем последнего входа
+// @Tags         passkeys
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   dto.PasskeyResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Router       /api/passkeys [get]
+func (h *PasskeyHandler) ListPasskeys(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	response, err := h.passkeyService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeletePasskey godoc
+// @Summary      Удалить passkey
+// @Description  Удаляет passkey, войти с ним больше нельзя. Уже выданные сессии не завершаются
+// @Tags         passkeys
+// @Produce      json
+// @Param        id   path      int  true  "Passkey ID"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/passkeys/{id} [delete]
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	passkeyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid passkey id"})
+		return
+	}
+
+	if err := h.passkeyService.Delete(c.Request.Context(), userID, passkeyID); err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "passkey deleted")
+}
+
+// BeginLogin godoc
+// @Summary      Начать вход по passkey
+// @Description  Возвращает параметры для navigator.credentials.get({publicKey}). Имя пользователя не нужно: аутентификатор сам предлагает сохраненные passkeys
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  dto.PasskeyRequestOptionsResponse
+// @Router       /login/passkey/begin [post]
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	response, err := h.passkeyService.BeginLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "failed to start passkey login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// FinishLogin godoc
+// @Summary      Вход по passkey
+// @Description  Проверяет подпись и счетчик passkey и выдает JWT токены так же, как /login. Passkey с проверкой пользователя заменяет оба фактора, код 2FA не запрашивается
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.FinishPasskeyLoginRequest true "Ответ navigator.credentials.get"
+// @Success      200  {object}  dto.TokenResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /login/passkey/finish [post]
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var req dto.FinishPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tokens, err := h.passkeyService.FinishLogin(c.Request.Context(), req, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPasskey), errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: services.ErrInvalidPasskey.Error()})
+		case errors.Is(err, services.ErrAccountBanned):
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "login failed"})
+		}
+		return
+	}
+
+	h.cookies.SetAccessToken(c, tokens.AccessToken)
+	h.cookies.SetRefreshToken(c, tokens.RefreshToken)
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func respondPasskeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPasskey):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrPasskeyAlreadyRegistered):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrPasskeyNotFound), errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrGuestNotAllowed):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
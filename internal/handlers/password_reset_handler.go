@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetHandler struct {
+	passwordResetService *services.PasswordResetService
+}
+
+func NewPasswordResetHandler(passwordResetService *services.PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		passwordResetService: passwordResetService,
+	}
+}
+
+// ForgotPassword godoc
+// @Summary      Запрос на сброс пароля
+// @Description  Отправляет на подтвержденную почту одноразовую ссылку для сброса пароля. Ответ одинаковый независимо от того, существует ли аккаунт
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.ForgotPasswordRequest true "Email аккаунта"
+// @Success      202  {object}  string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Router       /password/forgot [post]
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.passwordResetService.RequestReset(c.Request.Context(), req); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "failed to process password reset request"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, "if the account exists, reset instructions were sent")
+}
+
+// ResetPassword godoc
+// @Summary      Сброс пароля
+// @Description  Устанавливает новый пароль по одноразовому токену из письма и завершает все сессии пользователя
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.ResetPasswordRequest true "Токен сброса и новый пароль"
+// @Success      200  {object}  string
+// @Failure      400  {object}  dto.ValidationErrorResponse
+// @Router       /password/reset [post]
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.passwordResetService.ResetPassword(c.Request.Context(), req); err != nil {
+		if respondPasswordPolicyError(c, "new_password", err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, "password has been reset")
+}
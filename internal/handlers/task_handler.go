@@ -0,0 +1,384 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"user-service/internal/authz"
+	"user-service/internal/dto"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskHandler struct {
+	taskService *services.TaskService
+}
+
+func NewTaskHandler(taskService *services.TaskService) *TaskHandler {
+	return &TaskHandler{
+		taskService: taskService,
+	}
+}
+
+// AdminListTasks godoc
+// @Summary      Список заданий для администратора
+// @Description  Возвращает все задания по возрастанию ID. Архивные задания возвращаются только с include_archived=true
+// @Tags         admin
+// @Produce      json
+// @Param        include_archived  query     bool  false  "Включить архивные задания"
+// @Security     BearerAuth
+// @Success      200  {array}   dto.TaskResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/admin/tasks [get]
+func (h *TaskHandler) AdminListTasks(c *gin.Context) {
+	if !authorize(c, authz.ActionManageTasks, authz.None()) {
+		return
+	}
+
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
+
+	response, err := h.taskService.ListAll(c.Request.Context(), includeArchived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateTask godoc
+// @Summary      Создать задание
+// @Description  Создает задание. Название обязательно и не длиннее 255 символов, количество поинтов должно быть больше нуля. Категория — строка из строчных латинских букв, цифр, "-" и "_", по умолчанию general. verification_mode: auto (засчитывается сразу, по умолчанию), proof (нужно доказательство) или external (проверка модератором во внешней системе)
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateTaskRequest true "Задание"
+// @Security     BearerAuth
+// @Success      201  {object}  dto.TaskResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/admin/tasks [post]
+func (h *TaskHandler) CreateTask(c *gin.Context) {
+	if !authorize(c, authz.ActionManageTasks, authz.None()) {
+		return
+	}
+
+	var req dto.CreateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.taskService.Create(c.Request.Context(), req)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateTask godoc
+// @Summary      Изменить задание
+// @Description  Меняет переданные поля задания. Новое количество поинтов действует только для следующих выполнений, в том числе для еще не одобренных отправок. Новый verification_mode действует для следующих отправок
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                    true  "Task ID"
+// @Param        request  body  dto.UpdateTaskRequest  true  "Изменяемые поля"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.TaskResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/tasks/{id} [patch]
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	if !authorize(c, authz.ActionManageTasks, authz.None()) {
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid task id"})
+		return
+	}
+
+	var req dto.UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.taskService.Update(c.Request.Context(), taskID, req)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ArchiveTask godoc
+// @Summary      Архивировать задание
+// @Description  Скрывает задание: его больше нельзя выполнить, но выполненные задания и начисленные поинты пользователей сохраняются
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Task ID"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.TaskResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/tasks/{id}/archive [post]
+func (h *TaskHandler) ArchiveTask(c *gin.Context) {
+	if !authorize(c, authz.ActionManageTasks, authz.None()) {
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid task id"})
+		return
+	}
+
+	response, err := h.taskService.Archive(c.Request.Context(), taskID)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListTasks godoc
+// @Summary      Каталог заданий
+// @Description  Возвращает активные задания по возрастанию ID со способом проверки verification_mode и статусом отправки текущего пользователя submission_status. completed означает, что отправка одобрена, available — что задание еще не отправлялось или было отклонено. Для следующей страницы передайте next_cursor из ответа в параметре cursor
+// @Tags         tasks
+// @Produce      json
+// @Param        category  query     string  false  "Категория"
+// @Param        status    query     string  false  "all, completed, pending или available"
+// @Param        limit     query     int     false  "Размер страницы, от 1 до 100, по умолчанию 20"
+// @Param        cursor    query     string  false  "Курсор следующей страницы"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.TaskCatalogResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/tasks [get]
+func (h *TaskHandler) ListTasks(c *gin.Context) {
+	if !authorize(c, authz.ActionViewTasks, authz.None()) {
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	var query dto.TaskCatalogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.taskService.Catalog(c.Request.Context(), userID, query)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListSubmissions godoc
+// @Summary      Очередь проверки заданий
+// @Description  Возвращает отправки заданий в статусе status (по умолчанию pending) от старых к новым, с доказательствами и автором. Для следующей страницы передайте next_cursor из ответа в параметре cursor
+// @Tags         admin
+// @Produce      json
+// @Param        status  query     string  false  "pending, approved или rejected"
+// @Param        limit   query     int     false  "Размер страницы, от 1 до 100, по умолчанию 20"
+// @Param        cursor  query     string  false  "Курсор следующей страницы"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.TaskSubmissionsResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/admin/task-submissions [get]
+func (h *TaskHandler) ListSubmissions(c *gin.Context) {
+	if !authorize(c, authz.ActionModerateTasks, authz.None()) {
+		return
+	}
+
+	var query dto.SubmissionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.taskService.ListSubmissions(c.Request.Context(), query)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadProof godoc
+// @Summary      Файл доказательства
+// @Description  Отдает файл, приложенный к отправке задания, как вложение
+// @Tags         admin
+// @Produce      octet-stream
+// @Param        id   path      int  true  "Submission ID"
+// @Security     BearerAuth
+// @Success      200  {file}    file
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /api/admin/task-submissions/{id}/proof [get]
+func (h *TaskHandler) DownloadProof(c *gin.Context) {
+	if !authorize(c, authz.ActionModerateTasks, authz.None()) {
+		return
+	}
+
+	submissionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid submission id"})
+		return
+	}
+
+	proof, err := h.taskService.OpenProof(c.Request.Context(), submissionID)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+	defer proof.Content.Close()
+
+	// The file was uploaded by a user, so browsers must neither render it
+	// in place nor guess another type for it.
+	c.DataFromReader(http.StatusOK, -1, proof.ContentType, proof.Content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": proof.Name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// ApproveSubmission godoc
+// @Summary      Одобрить отправку задания
+// @Description  Засчитывает задание и начисляет автору текущее количество поинтов задания. Проверять свои отправки нельзя
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Submission ID"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.ReviewSubmissionResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/admin/task-submissions/{id}/approve [post]
+func (h *TaskHandler) ApproveSubmission(c *gin.Context) {
+	reviewerID, submissionID, ok := h.reviewerAndSubmission(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.taskService.Approve(c.Request.Context(), reviewerID, submissionID)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RejectSubmission godoc
+// @Summary      Отклонить отправку задания
+// @Description  Отклоняет отправку с обязательной причиной, которую увидит пользователь. Поинты не начисляются, задание можно отправить снова. Проверять свои отправки нельзя
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path  int                          true  "Submission ID"
+// @Param        request  body  dto.RejectSubmissionRequest  true  "Причина"
+// @Security     BearerAuth
+// @Success      200  {object}  dto.ReviewSubmissionResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Router       /api/admin/task-submissions/{id}/reject [post]
+func (h *TaskHandler) RejectSubmission(c *gin.Context) {
+	reviewerID, submissionID, ok := h.reviewerAndSubmission(c)
+	if !ok {
+		return
+	}
+
+	var req dto.RejectSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "reason is required"})
+		return
+	}
+
+	response, err := h.taskService.Reject(c.Request.Context(), reviewerID, submissionID, req.Reason)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *TaskHandler) reviewerAndSubmission(c *gin.Context) (int, int, bool) {
+	if !authorize(c, authz.ActionModerateTasks, authz.None()) {
+		return 0, 0, false
+	}
+
+	reviewerID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return 0, 0, false
+	}
+
+	submissionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid submission id"})
+		return 0, 0, false
+	}
+
+	return reviewerID, submissionID, true
+}
+
+func respondTaskError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTaskNotFound),
+		errors.Is(err, services.ErrSubmissionNotFound),
+		errors.Is(err, services.ErrProofFileAbsent):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrSubmissionReviewed):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrOwnSubmission):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidTaskTitle),
+		errors.Is(err, services.ErrInvalidTaskCategory),
+		errors.Is(err, services.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"user-service/internal/authz"
+	"user-service/internal/config"
+	"user-service/internal/domain"
 	"user-service/internal/dto"
 	"user-service/internal/middleware"
 	"user-service/internal/services"
@@ -10,36 +14,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// proofFormOverhead is the room left in a multipart submission for the form
+// fields and part headers next to the proof file.
+const proofFormOverhead = 64 << 10
+
 type UserHandler struct {
-	userService *services.UserService
+	userService   *services.UserService
+	maxProofBytes int64
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
+func NewUserHandler(userService *services.UserService, proofCfg config.TaskProofConfig) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:   userService,
+		maxProofBytes: proofCfg.MaxBytes,
 	}
 }
 
 // GetStatus godoc
 // @Summary      Получить статус пользователя
-// @Description  Возвращает информацию о пользователе и его выполненных заданиях
+// @Description  Возвращает информацию о пользователе и его выполненных заданиях. Чужой статус доступен только модераторам и администраторам
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        id   path      int  true  "User ID"
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Success      200  {object}  dto.UserStatusResponse
 // @Failure      401  {object}  dto.ErrorResponse
 // @Failure      403  {object}  dto.ErrorResponse
 // @Failure      404  {object}  dto.ErrorResponse
 // @Router       /api/users/{id}/status [get]
 func (h *UserHandler) GetStatus(c *gin.Context) {
-	currentUserID, ok := middleware.GetUserIDFromContext(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
-		return
-	}
-
 	idParam := c.Param("id")
 	requestedUserID, err := strconv.Atoi(idParam)
 	if err != nil {
@@ -47,8 +52,7 @@ func (h *UserHandler) GetStatus(c *gin.Context) {
 		return
 	}
 
-	if currentUserID != requestedUserID {
-		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "access denied"})
+	if !authorize(c, authz.ActionViewUserStatus, authz.User(requestedUserID)) {
 		return
 	}
 
@@ -69,10 +73,16 @@ func (h *UserHandler) GetStatus(c *gin.Context) {
 // @Produce      json
 // @Param        limit  query     int  false  "Количество пользователей"  default(10)
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Success      200  {object}  string
 // @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
 // @Router       /api/users/leaderboard [get]
 func (h *UserHandler) GetLeaderBoard(c *gin.Context) {
+	if !authorize(c, authz.ActionViewLeaderboard, authz.None()) {
+		return
+	}
+
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, _ := strconv.Atoi(limitStr)
 
@@ -85,59 +95,189 @@ func (h *UserHandler) GetLeaderBoard(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetLeaderboard godoc
-// @Summary      Выполнить задание, получить поинты
-// @Description  Возвращает сообщение при успешном выполнении
+// GetCompletedTasks godoc
+// @Summary      История выполненных заданий
+// @Description  Возвращает отправленные текущим пользователем задания, включая архивные, от последних отправленных к ранним. У каждой отправки есть статус pending, approved или rejected, у отклоненных — причина rejection_reason. Для следующей страницы передайте next_cursor из ответа в параметре cursor
+// @Tags         users
+// @Produce      json
+// @Param        limit   query     int     false  "Размер страницы, от 1 до 100, по умолчанию 20"
+// @Param        cursor  query     string  false  "Курсор следующей страницы"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.CompletedTasksResponse
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Router       /api/users/me/tasks [get]
+func (h *UserHandler) GetCompletedTasks(c *gin.Context) {
+	userID, ok := middleware.GetUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+		return
+	}
+
+	if !authorize(c, authz.ActionViewTaskHistory, authz.User(userID)) {
+		return
+	}
+
+	var query dto.PageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	response, err := h.userService.GetUserCompletedTasks(c.Request.Context(), userID, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CompleteTask godoc
+// @Summary      Выполнить задание
+// @Description  Отправляет задание на проверку. Задания с verification_mode=auto засчитываются сразу и поинты начисляются в ответ на запрос (200). Остальные ждут решения модератора (202), поинты начисляются только после одобрения. Для proof нужно доказательство: proof_text, proof_url или файл proof_file (PNG, JPEG, WebP или PDF не больше TASK_PROOF_MAX_BYTES), файл передается в multipart/form-data. Отклоненное задание можно отправить снова. Гостям доступны только задания auto. Выполнять задания можно только за себя. Может требовать подтвержденный email
 // @Tags         users
 // @Accept       json
+// @Accept       mpfd
 // @Produce      json
-// @Param        id  path     int  true  "task ID"
+// @Param        id          path      int                      true   "User ID"
+// @Param        request     body      dto.CompleteTaskRequest  true   "Задание и доказательство"
+// @Param        proof_file  formData  file                     false  "Файл доказательства"
 // @Security     BearerAuth
-// @Success      200  {object}  string
+// @Security     ApiKeyAuth
+// @Success      200  {object}  dto.TaskSubmissionResponse
+// @Success      202  {object}  dto.TaskSubmissionResponse
 // @Failure      400  {object}  dto.ErrorResponse
 // @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      413  {object}  dto.ErrorResponse
 // @Router       /api/users/{id}/task/complete [post]
 func (h *UserHandler) CompleteTask(c *gin.Context) {
-	currentID, ok := middleware.GetUserIDFromContext(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid ID"})
 		return
 	}
 
-	idParam := c.Param("id")
-	taskIDParam, _ := strconv.Atoi(idParam)
+	if !authorize(c, authz.ActionCompleteTask, authz.User(userID)) {
+		return
+	}
+
+	var req dto.CompleteTaskRequest
+	var proof *services.ProofFile
+
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxProofBytes+proofFormOverhead)
 
-	if err := h.userService.CompleteTask(c.Request.Context(), currentID, taskIDParam); err != nil {
+		if err := c.ShouldBind(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		header, err := c.FormFile("proof_file")
+		switch {
+		case err == nil:
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "failed to read proof file"})
+				return
+			}
+			defer file.Close()
+
+			proof = &services.ProofFile{Name: header.Filename, Size: header.Size, Content: file}
+		case !errors.Is(err, http.ErrMissingFile):
+			respondBindError(c, err)
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, "task completed")
+	response, err := h.userService.CompleteTask(c.Request.Context(), userID, req, proof)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailNotVerified), errors.Is(err, services.ErrGuestNotAllowed):
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTaskNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTaskAlreadySubmitted):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrProofRequired), errors.Is(err, services.ErrProofFileType):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrProofTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+
+	if response.Status == domain.SubmissionPending {
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondBindError answers a multipart form that could not be read, telling
+// a body over the size limit apart from a malformed one.
+func respondBindError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{Error: services.ErrProofTooLarge.Error()})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
 }
 
 // AddReferrer godoc
 // @Summary      Ввести реферальный код
-// @Description  Устанавливает реферера для пользователя и начисляет бонусы
+// @Description  Устанавливает реферера для пользователя и начисляет рефереру бонус. Указать реферера можно только себе и не из гостевого аккаунта. Может требовать подтвержденный email
 // @Tags         users
 // @Accept       json
 // @Produce      json
-// @Param        id   path      int  true  "User ID"
+// @Param        id       path  int                     true  "User ID"
+// @Param        request  body  dto.AddReferrerRequest  true  "Реферер"
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Success      200  {object}  string
 // @Failure      400  {object}  dto.ErrorResponse
 // @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
 // @Router       /api/users/{id}/referrer [post]
 func (h *UserHandler) AddReferrer(c *gin.Context) {
-	currentUserID, ok := middleware.GetUserIDFromContext(c)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "not authorized"})
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid ID"})
 		return
 	}
 
-	idParam := c.Param("id")
-	requestedUserID, _ := strconv.Atoi(idParam)
+	if !authorize(c, authz.ActionSetReferrer, authz.User(userID)) {
+		return
+	}
+
+	var req dto.AddReferrerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
 
-	if err := h.userService.AddReferrer(c.Request.Context(), currentUserID, requestedUserID); err != nil {
+	if err := h.userService.AddReferrer(c.Request.Context(), userID, req.ReferrerID); err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) || errors.Is(err, services.ErrGuestNotAllowed) {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/dto"
+	"user-service/internal/password"
+	"user-service/internal/username"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondPasswordPolicyError answers with the policy violations attached to
+// the given request field. It reports false when err is not a policy error.
+func respondPasswordPolicyError(c *gin.Context, field string, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, dto.ValidationErrorResponse{
+		Error:  "password does not meet the policy",
+		Fields: map[string][]string{field: policyErr.Violations},
+	})
+
+	return true
+}
+
+// respondUsernameError answers with the naming rule the requested username
+// breaks. It reports false when err is not about the username format.
+func respondUsernameError(c *gin.Context, err error) bool {
+	if !errors.Is(err, username.ErrInvalidLength) &&
+		!errors.Is(err, username.ErrInvalidCharacters) &&
+		!errors.Is(err, username.ErrReserved) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, dto.ValidationErrorResponse{
+		Error:  "username is not allowed",
+		Fields: map[string][]string{"username": {err.Error()}},
+	})
+
+	return true
+}
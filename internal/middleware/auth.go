@@ -0,0 +1,284 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"user-service/internal/authz"
+	"user-service/internal/csrf"
+	"user-service/internal/dto"
+	"user-service/internal/rbac"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthMiddleware struct {
+	jwtService        *services.JWTService
+	revocationService *services.RevocationService
+	apiKeyService     *services.APIKeyService
+	trustedOrigins    []string
+}
+
+func NewAuthMiddleware(
+	jwtService *services.JWTService,
+	revocationService *services.RevocationService,
+	apiKeyService *services.APIKeyService,
+	trustedOrigins []string,
+) *AuthMiddleware {
+	return &AuthMiddleware{
+		jwtService:        jwtService,
+		revocationService: revocationService,
+		apiKeyService:     apiKeyService,
+		trustedOrigins:    trustedOrigins,
+	}
+}
+
+// Authenticate accepts an access token from the Authorization header or the
+// access_token cookie, or an API key from the X-API-Key or Authorization
+// header. Browsers attach the cookie to cross-site requests too, so
+// state-changing requests authenticated by it must also pass the CSRF checks.
+func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			m.authenticateAPIKey(c, apiKey)
+			return
+		}
+
+		// An explicit Authorization header wins over the cookie, so clients
+		// using bearer tokens never need CSRF tokens even if a browser also
+		// sends the cookie.
+		tokenString, fromCookie := "", false
+
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+					Error: "invalid format Authorization header",
+				})
+				c.Abort()
+				return
+			}
+
+			tokenString = parts[1]
+
+			if strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+				m.authenticateAPIKey(c, tokenString)
+				return
+			}
+		} else if cookie, err := c.Cookie("access_token"); err == nil && cookie != "" {
+			tokenString, fromCookie = cookie, true
+		} else {
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error: "token is missing",
+			})
+			c.Abort()
+			return
+		}
+
+		claims, err := m.jwtService.ValidateAccessToken(tokenString)
+		if err != nil || claims.TokenUse != services.TokenUseAccess {
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error: "invalid or expired token",
+			})
+			c.Abort()
+			return
+		}
+
+		if fromCookie && !csrf.Safe(c.Request.Method) && !m.checkCSRF(c, tokenString) {
+			return
+		}
+
+		revoked, err := m.revocationService.IsRevoked(c.Request.Context(), claims)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, dto.ErrorResponse{
+				Error: "failed to check token revocation",
+			})
+			c.Abort()
+			return
+		}
+
+		if revoked {
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error: "token has been revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		subject := authz.Subject{
+			UserID: claims.UserID,
+			Role:   rbac.Normalize(claims.Role),
+		}
+
+		c.Set("claims", claims)
+		c.Set("userID", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("role", rbac.Normalize(claims.Role))
+		c.Set("sessionID", claims.SessionID)
+
+		if claims.Impersonated() {
+			c.Set("actorID", claims.Actor.UserID)
+			subject.ActorID = claims.Actor.UserID
+		}
+
+		if claims.ReadOnly && !csrf.Safe(c.Request.Method) {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{
+				Error: "this token is read-only",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(authz.WithSubject(c.Request.Context(), subject))
+
+		c.Next()
+	}
+}
+
+func (m *AuthMiddleware) checkCSRF(c *gin.Context, accessToken string) bool {
+	if !csrf.OriginAllowed(c.Request, m.trustedOrigins) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error: "cross-origin request rejected",
+		})
+		c.Abort()
+		return false
+	}
+
+	if !csrf.Valid(accessToken, c.GetHeader(csrf.HeaderName)) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error: "missing or invalid CSRF token",
+		})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, rawKey string) {
+	key, err := m.apiKeyService.Authenticate(c.Request.Context(), rawKey, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: err.Error()})
+		} else {
+			c.JSON(http.StatusServiceUnavailable, dto.ErrorResponse{Error: "failed to check API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", key.UserID)
+	c.Set("apiKeyID", key.ID)
+	c.Set("scopes", key.ScopeList())
+
+	c.Request = c.Request.WithContext(authz.WithSubject(c.Request.Context(), authz.Subject{
+		UserID: key.UserID,
+		Scopes: key.ScopeList(),
+	}))
+
+	c.Next()
+}
+
+// RequireSession rejects API keys on routes that manage the account itself,
+// so a leaked key cannot be used to take the account over. Impersonation
+// tokens are rejected too: staff acting as a user must not change the
+// user's credentials or reach the admin routes with the user's role.
+func (m *AuthMiddleware) RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := GetAPIKeyIDFromContext(c); isAPIKey {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{
+				Error: "this endpoint is not available to API keys",
+			})
+			c.Abort()
+			return
+		}
+
+		if _, impersonated := GetActorIDFromContext(c); impersonated {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{
+				Error: "this endpoint is not available while impersonating",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func GetUserIDFromContext(c *gin.Context) (int, bool) {
+	userID, ok := c.Get("userID")
+	if !ok {
+		return 0, false
+	}
+	return userID.(int), true
+}
+
+func GetUsernameFromContext(c *gin.Context) (string, bool) {
+	username, ok := c.Get("username")
+	if !ok {
+		return "", false
+	}
+
+	return username.(string), true
+}
+
+func GetSessionIDFromContext(c *gin.Context) (int, bool) {
+	sessionID, ok := c.Get("sessionID")
+	if !ok {
+		return 0, false
+	}
+
+	return sessionID.(int), true
+}
+
+func GetClaimsFromContext(c *gin.Context) (*services.Claims, bool) {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return nil, false
+	}
+
+	return claims.(*services.Claims), true
+}
+
+// GetRoleFromContext returns the role carried by the access token. Requests
+// made with API keys have no role.
+func GetRoleFromContext(c *gin.Context) (string, bool) {
+	role, ok := c.Get("role")
+	if !ok {
+		return "", false
+	}
+
+	return role.(string), true
+}
+
+func GetAPIKeyIDFromContext(c *gin.Context) (int, bool) {
+	keyID, ok := c.Get("apiKeyID")
+	if !ok {
+		return 0, false
+	}
+
+	return keyID.(int), true
+}
+
+// GetScopesFromContext returns the scopes of the API key the request was
+// authenticated with. It reports false for requests made with access tokens.
+func GetScopesFromContext(c *gin.Context) ([]string, bool) {
+	scopes, ok := c.Get("scopes")
+	if !ok {
+		return nil, false
+	}
+
+	return scopes.([]string), true
+}
+
+// GetActorIDFromContext returns the staff member behind an impersonation
+// token. It reports false for every other request.
+func GetActorIDFromContext(c *gin.Context) (int, bool) {
+	actorID, ok := c.Get("actorID")
+	if !ok {
+		return 0, false
+	}
+
+	return actorID.(int), true
+}
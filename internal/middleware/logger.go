@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLogFormatter is the gin request log line with the authenticated
+// user appended, and for impersonation tokens the staff member behind them,
+// so every request made while impersonating can be traced back.
+func RequestLogFormatter(param gin.LogFormatterParams) string {
+	var who strings.Builder
+
+	if userID, ok := param.Keys["userID"].(int); ok {
+		fmt.Fprintf(&who, " user=%d", userID)
+	}
+	if actorID, ok := param.Keys["actorID"].(int); ok {
+		fmt.Fprintf(&who, " act=%d", actorID)
+	}
+	if keyID, ok := param.Keys["apiKeyID"].(int); ok {
+		fmt.Fprintf(&who, " api_key=%d", keyID)
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v%s\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		param.Path,
+		who.String(),
+		param.ErrorMessage,
+	)
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"user-service/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole lets through users that have one of the roles. It has to run
+// after Authenticate.
+func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := GetRoleFromContext(c)
+		if !ok || !slices.Contains(roles, role) {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "access denied"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
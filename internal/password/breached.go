@@ -0,0 +1,113 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const hashPrefixLength = 5
+
+type BreachChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// NewBreachChecker opens a local copy of a breached password dataset keyed by
+// SHA-1. A directory is read as k-anonymity range files, one per five
+// character hash prefix (ABCDE or ABCDE.txt) with SUFFIX:COUNT lines, exactly
+// as served by the Pwned Passwords range API, so only the matching file is
+// read per check. A regular file is read as HASH:COUNT lines and kept in
+// memory, which suits short curated lists.
+func NewBreachChecker(path string) (BreachChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+
+	if info.IsDir() {
+		return &rangeDirChecker{dir: path}, nil
+	}
+
+	return loadHashFile(path)
+}
+
+type rangeDirChecker struct {
+	dir string
+}
+
+func (c *rangeDirChecker) IsBreached(password string) (bool, error) {
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		found, err := scanForHash(filepath.Join(c.dir, name), suffix)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return found, err
+	}
+
+	return false, nil
+}
+
+type hashSetChecker struct {
+	hashes map[string]struct{}
+}
+
+func loadHashFile(path string) (*hashSetChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	checker := &hashSetChecker{hashes: make(map[string]struct{})}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) == sha1.Size*2 {
+			checker.hashes[strings.ToUpper(hash)] = struct{}{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return checker, nil
+}
+
+func (c *hashSetChecker) IsBreached(password string) (bool, error) {
+	_, found := c.hashes[sha1Hex(password)]
+	return found, nil
+}
+
+func scanForHash(path, suffix string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,151 @@
+// Package password holds the rules every new password has to satisfy.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"user-service/internal/config"
+)
+
+// BcryptMaxBytes is the length after which bcrypt silently ignores input.
+const BcryptMaxBytes = 72
+
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassLetter = "letter"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// PolicyError lists every rule a password broke, so clients can show all of
+// them next to the field at once.
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+type Policy struct {
+	minLength       int
+	maxBytes        int
+	requiredClasses []string
+	breached        BreachChecker
+}
+
+func NewPolicy(cfg config.PasswordPolicyConfig, breached BreachChecker) (*Policy, error) {
+	for _, class := range cfg.RequiredClasses {
+		switch class {
+		case ClassLower, ClassUpper, ClassLetter, ClassDigit, ClassSymbol:
+		default:
+			return nil, fmt.Errorf("unknown password character class %q", class)
+		}
+	}
+
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 || maxBytes > BcryptMaxBytes {
+		maxBytes = BcryptMaxBytes
+	}
+
+	return &Policy{
+		minLength:       cfg.MinLength,
+		maxBytes:        maxBytes,
+		requiredClasses: cfg.RequiredClasses,
+		breached:        breached,
+	}, nil
+}
+
+// Validate returns a *PolicyError describing all violations, or another error
+// if the breached password list could not be consulted.
+func (p *Policy) Validate(username, password string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.minLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.minLength))
+	}
+
+	if len(password) > p.maxBytes {
+		violations = append(violations, fmt.Sprintf("must not be longer than %d bytes", p.maxBytes))
+	}
+
+	for _, class := range p.requiredClasses {
+		if !containsClass(password, class) {
+			violations = append(violations, "must contain at least one "+classDescription(class))
+		}
+	}
+
+	if username != "" {
+		lowerPassword := strings.ToLower(password)
+		lowerUsername := strings.ToLower(username)
+
+		if lowerPassword == lowerUsername {
+			violations = append(violations, "must not be the same as the username")
+		} else if strings.Contains(lowerPassword, lowerUsername) {
+			violations = append(violations, "must not contain the username")
+		}
+	}
+
+	if p.breached != nil && password != "" {
+		breached, err := p.breached.IsBreached(password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, "appears in a list of breached passwords, choose another one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func containsClass(password, class string) bool {
+	for _, r := range password {
+		switch class {
+		case ClassLower:
+			if unicode.IsLower(r) {
+				return true
+			}
+		case ClassUpper:
+			if unicode.IsUpper(r) {
+				return true
+			}
+		case ClassLetter:
+			if unicode.IsLetter(r) {
+				return true
+			}
+		case ClassDigit:
+			if unicode.IsDigit(r) {
+				return true
+			}
+		case ClassSymbol:
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func classDescription(class string) string {
+	switch class {
+	case ClassLower:
+		return "lowercase letter"
+	case ClassUpper:
+		return "uppercase letter"
+	case ClassLetter:
+		return "letter"
+	case ClassDigit:
+		return "digit"
+	default:
+		return "symbol"
+	}
+}
@@ -0,0 +1,31 @@
+// Package proofstore keeps the files users attach as proof of completed
+// tasks. Files are addressed by keys the service generates; the original
+// file names are kept in the database only.
+package proofstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+var (
+	ErrNotFound   = errors.New("proof file not found")
+	ErrInvalidKey = errors.New("invalid proof file key")
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9]{16,64}(\.[a-z0-9]{1,10})?$`)
+
+type Store interface {
+	Save(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key can name a file. Keys never contain path
+// separators, so a stored key cannot point outside the store.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
@@ -0,0 +1,72 @@
+// Package rbac defines the user roles and the permissions each one grants.
+package rbac
+
+import "slices"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+type Permission string
+
+const (
+	PermViewAnyUser    Permission = "users:view_any"
+	PermBanUsers       Permission = "users:ban"
+	PermManageRoles    Permission = "users:manage_roles"
+	PermManageLockouts Permission = "lockouts:manage"
+	PermImpersonate    Permission = "users:impersonate"
+	PermManageTasks    Permission = "tasks:manage"
+	PermModerateTasks  Permission = "tasks:moderate"
+)
+
+// roles lists the roles from least to most privileged.
+var roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+var rolePermissions = map[string][]Permission{
+	RoleUser: {},
+	RoleModerator: {
+		PermViewAnyUser,
+		PermBanUsers,
+		PermManageLockouts,
+		PermModerateTasks,
+	},
+	RoleAdmin: {
+		PermViewAnyUser,
+		PermBanUsers,
+		PermManageRoles,
+		PermManageLockouts,
+		PermImpersonate,
+		PermManageTasks,
+		PermModerateTasks,
+	},
+}
+
+func Roles() []string {
+	return slices.Clone(roles)
+}
+
+func ValidRole(role string) bool {
+	return slices.Contains(roles, role)
+}
+
+// Normalize maps the empty role of tokens issued before roles existed to
+// RoleUser.
+func Normalize(role string) string {
+	if role == "" {
+		return RoleUser
+	}
+
+	return role
+}
+
+func HasPermission(role string, permission Permission) bool {
+	return slices.Contains(rolePermissions[Normalize(role)], permission)
+}
+
+// Outranks reports whether role is strictly more privileged than other, which
+// is required to ban someone or change their role.
+func Outranks(role, other string) bool {
+	return slices.Index(roles, Normalize(role)) > slices.Index(roles, Normalize(other))
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	FindByHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	ListActiveByUser(ctx context.Context, userID int) ([]domain.APIKey, error)
+	CountActiveByUser(ctx context.Context, userID int) (int, error)
+	TouchLastUsed(ctx context.Context, id int, ipAddress string, staleBefore time.Time) error
+	Revoke(ctx context.Context, userID, id int) (bool, error)
+	RevokeAllByUser(ctx context.Context, userID int) error
+}
+
+type PostgresAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresAPIKeyRepository(db *gorm.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	result := r.db.WithContext(ctx).Create(key)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create API key: %w", result.Error)
+	}
+	return nil
+}
+
+// FindByHash looks a key up by its hash. Keys of accounts pending deletion
+// are not found, so they stop working until the deletion is cancelled.
+func (r *PostgresAPIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+
+	result := r.db.WithContext(ctx).
+		Joins("JOIN users ON users.id = api_keys.user_id").
+		Where("api_keys.key_hash = ? AND users.deleted_at IS NULL", keyHash).
+		First(&key)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get API key: %w", result.Error)
+	}
+
+	return &key, nil
+}
+
+func (r *PostgresAPIKeyRepository) ListActiveByUser(ctx context.Context, userID int) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+
+	result := r.activeByUser(ctx, userID).Order("created_at DESC").Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return keys, nil
+}
+
+func (r *PostgresAPIKeyRepository) CountActiveByUser(ctx context.Context, userID int) (int, error) {
+	var count int64
+
+	if err := r.activeByUser(ctx, userID).Model(&domain.APIKey{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+// TouchLastUsed records usage at most once per key until staleBefore passes,
+// so busy keys do not cause a write on every request.
+func (r *PostgresAPIKeyRepository) TouchLastUsed(ctx context.Context, id int, ipAddress string, staleBefore time.Time) error {
+	return r.db.WithContext(ctx).Model(&domain.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, staleBefore).
+		Updates(map[string]interface{}{
+			"last_used_at": time.Now().UTC(),
+			"last_used_ip": ipAddress,
+		}).Error
+}
+
+// Revoke reports false when the key does not exist, belongs to another user
+// or was already revoked.
+func (r *PostgresAPIKeyRepository) Revoke(ctx context.Context, userID, id int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *PostgresAPIKeyRepository) RevokeAllByUser(ctx context.Context, userID int) error {
+	return r.db.WithContext(ctx).Model(&domain.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+func (r *PostgresAPIKeyRepository) activeByUser(ctx context.Context, userID int) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now().UTC())
+}
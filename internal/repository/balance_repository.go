@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BalanceRepository interface {
+	Apply(ctx context.Context, entry *domain.BalanceTransaction) error
+	ListByUser(ctx context.Context, userID int) ([]domain.BalanceTransaction, error)
+}
+
+type PostgresBalanceRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresBalanceRepository(db *gorm.DB) *PostgresBalanceRepository {
+	return &PostgresBalanceRepository{
+		db: db,
+	}
+}
+
+// Apply adds the amount of the entry to the balance of its user and records
+// the entry in the ledger in one transaction. The increment happens in SQL,
+// so concurrent changes cannot overwrite each other.
+func (r *PostgresBalanceRepository) Apply(ctx context.Context, entry *domain.BalanceTransaction) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return applyBalance(tx, entry)
+	})
+}
+
+// applyBalance is Apply inside a transaction the caller already holds, for
+// repositories that credit the balance together with their own change.
+func applyBalance(tx *gorm.DB, entry *domain.BalanceTransaction) error {
+	if entry.UserID == nil {
+		return errors.New("balance transaction has no user")
+	}
+
+	var users []domain.User
+
+	result := tx.Model(&users).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "balance"}}}).
+		Where("id = ?", *entry.UserID).
+		Update("balance", gorm.Expr("balance + ?", entry.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if len(users) == 0 {
+		return errors.New("user is not found")
+	}
+
+	entry.BalanceAfter = users[0].Balance
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record balance transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresBalanceRepository) ListByUser(ctx context.Context, userID int) ([]domain.BalanceTransaction, error) {
+	var entries []domain.BalanceTransaction
+
+	result := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at, id").
+		Find(&entries)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return entries, nil
+}
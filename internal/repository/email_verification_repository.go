@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, token *domain.EmailVerificationToken) error
+	Consume(ctx context.Context, tokenHash string) (*domain.EmailVerificationToken, error)
+	InvalidateByUser(ctx context.Context, userID int) error
+}
+
+type PostgresEmailVerificationRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresEmailVerificationRepository(db *gorm.DB) *PostgresEmailVerificationRepository {
+	return &PostgresEmailVerificationRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresEmailVerificationRepository) Create(ctx context.Context, token *domain.EmailVerificationToken) error {
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save email verification token: %w", result.Error)
+	}
+	return nil
+}
+
+// Consume marks an unused, unexpired token as used in a single statement and
+// returns it, or nil when there is no such token.
+func (r *PostgresEmailVerificationRepository) Consume(ctx context.Context, tokenHash string) (*domain.EmailVerificationToken, error) {
+	var tokens []domain.EmailVerificationToken
+
+	now := time.Now().UTC()
+	result := r.db.WithContext(ctx).Model(&tokens).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}, {Name: "email"}}}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	return &tokens[0], nil
+}
+
+func (r *PostgresEmailVerificationRepository) InvalidateByUser(ctx context.Context, userID int) error {
+	result := r.db.WithContext(ctx).Model(&domain.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now().UTC())
+
+	return result.Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const pgUniqueViolation = "23505"
+
+// ConflictError reports that a write broke a unique constraint. Field names
+// the conflicting user field when it is known.
+type ConflictError struct {
+	Field      string
+	Constraint string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Field == "" {
+		return "record already exists"
+	}
+	return e.Field + " is already taken"
+}
+
+// asConflict turns a unique violation into a *ConflictError and returns any
+// other error unchanged.
+func asConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return err
+	}
+
+	conflict := &ConflictError{Constraint: pgErr.ConstraintName}
+	switch {
+	case strings.Contains(pgErr.ConstraintName, "username"):
+		conflict.Field = "username"
+	case strings.Contains(pgErr.ConstraintName, "email"):
+		conflict.Field = "email"
+	}
+
+	return conflict
+}
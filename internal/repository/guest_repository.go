@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type GuestRepository interface {
+	CreateGuest(ctx context.Context, user *domain.User, credential *domain.GuestCredential) error
+	FindCredential(ctx context.Context, secretHash string) (*domain.GuestCredential, error)
+	CountSinceByIP(ctx context.Context, ipAddress string, since time.Time) (int, error)
+	TouchCredential(ctx context.Context, userID int) error
+	Upgrade(ctx context.Context, userID int, username, passwordHash string, email *string) (bool, error)
+	DeleteInactive(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type PostgresGuestRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresGuestRepository(db *gorm.DB) *PostgresGuestRepository {
+	return &PostgresGuestRepository{
+		db: db,
+	}
+}
+
+// CreateGuest inserts the guest user together with its device credential.
+func (r *PostgresGuestRepository) CreateGuest(ctx context.Context, user *domain.User, credential *domain.GuestCredential) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create guest user: %w", asConflict(err))
+		}
+
+		credential.UserID = user.ID
+
+		if err := tx.Create(credential).Error; err != nil {
+			return fmt.Errorf("failed to save guest credential: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// CountSinceByIP counts the guests created from the IP since the given time.
+func (r *PostgresGuestRepository) CountSinceByIP(ctx context.Context, ipAddress string, since time.Time) (int, error) {
+	var count int64
+
+	result := r.db.WithContext(ctx).Model(&domain.GuestCredential{}).
+		Where("ip_address = ? AND created_at > ?", ipAddress, since).
+		Count(&count)
+
+	return int(count), result.Error
+}
+
+func (r *PostgresGuestRepository) FindCredential(ctx context.Context, secretHash string) (*domain.GuestCredential, error) {
+	var credential domain.GuestCredential
+
+	result := r.db.WithContext(ctx).Where("secret_hash = ?", secretHash).First(&credential)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get guest credential: %w", result.Error)
+	}
+
+	return &credential, nil
+}
+
+func (r *PostgresGuestRepository) TouchCredential(ctx context.Context, userID int) error {
+	result := r.db.WithContext(ctx).Model(&domain.GuestCredential{}).
+		Where("user_id = ?", userID).
+		Update("last_used_at", time.Now().UTC())
+
+	return result.Error
+}
+
+// Upgrade turns the guest into a full account with the given credentials and
+// drops its device credential. It reports false when the user is not a guest
+// (anymore). A taken username or email is reported as a *ConflictError.
+func (r *PostgresGuestRepository) Upgrade(ctx context.Context, userID int, username, passwordHash string, email *string) (bool, error) {
+	upgraded := false
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.User{}).
+			Where("id = ? AND is_guest", userID).
+			Updates(map[string]any{
+				"username":          username,
+				"password_hash":     passwordHash,
+				"email":             email,
+				"email_verified_at": nil,
+				"is_guest":          false,
+			})
+		if result.Error != nil {
+			return asConflict(result.Error)
+		}
+
+		upgraded = result.RowsAffected == 1
+		if !upgraded {
+			return nil
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&domain.GuestCredential{}).Error
+	})
+
+	return upgraded, err
+}
+
+// DeleteInactive removes guest accounts that neither signed in with their
+// device credential nor used a session since cutoff, and reports how many
+// were removed.
+func (r *PostgresGuestRepository) DeleteInactive(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("is_guest").
+		Where("NOT EXISTS (SELECT 1 FROM guest_credentials g WHERE g.user_id = users.id AND g.last_used_at > ?)", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM sessions s WHERE s.user_id = users.id AND s.last_used_at > ?)", cutoff).
+		Delete(&domain.User{})
+
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepository interface {
+	SaveAuthRequest(ctx context.Context, request *domain.OIDCAuthRequest) error
+	ConsumeAuthRequest(ctx context.Context, stateHash string) (*domain.OIDCAuthRequest, error)
+	FindIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error)
+	ListIdentities(ctx context.Context, userID int) ([]domain.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error
+	CreateUserWithIdentity(ctx context.Context, user *domain.User, identity *domain.UserIdentity) error
+	TouchIdentity(ctx context.Context, id int) error
+}
+
+type PostgresIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresIdentityRepository(db *gorm.DB) *PostgresIdentityRepository {
+	return &PostgresIdentityRepository{
+		db: db,
+	}
+}
+
+// SaveAuthRequest stores a pending authorization request and drops expired
+// ones, so abandoned logins do not pile up.
+func (r *PostgresIdentityRepository) SaveAuthRequest(ctx context.Context, request *domain.OIDCAuthRequest) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now().UTC()).Delete(&domain.OIDCAuthRequest{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(request).Error; err != nil {
+			return fmt.Errorf("failed to save OIDC auth request: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ConsumeAuthRequest deletes the request in the same statement that reads it,
+// so a state value can be redeemed once. It returns nil for unknown or
+// expired states.
+func (r *PostgresIdentityRepository) ConsumeAuthRequest(ctx context.Context, stateHash string) (*domain.OIDCAuthRequest, error) {
+	var requests []domain.OIDCAuthRequest
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("state_hash = ?", stateHash).
+		Delete(&requests)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume OIDC auth request: %w", result.Error)
+	}
+
+	if len(requests) == 0 || time.Now().UTC().After(requests[0].ExpiresAt) {
+		return nil, nil
+	}
+
+	return &requests[0], nil
+}
+
+func (r *PostgresIdentityRepository) FindIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+
+	result := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get identity: %w", result.Error)
+	}
+
+	return &identity, nil
+}
+
+func (r *PostgresIdentityRepository) ListIdentities(ctx context.Context, userID int) ([]domain.UserIdentity, error) {
+	var identities []domain.UserIdentity
+
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return identities, nil
+}
+
+func (r *PostgresIdentityRepository) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	result := r.db.WithContext(ctx).Create(identity)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create identity: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresIdentityRepository) CreateUserWithIdentity(ctx context.Context, user *domain.User, identity *domain.UserIdentity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create user: %w", asConflict(err))
+		}
+
+		identity.UserID = user.ID
+		if err := tx.Create(identity).Error; err != nil {
+			return fmt.Errorf("failed to create identity: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *PostgresIdentityRepository) TouchIdentity(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Model(&domain.UserIdentity{}).
+		Where("id = ?", id).
+		Update("last_login_at", time.Now().UTC()).Error
+}
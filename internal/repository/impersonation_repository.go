@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type ImpersonationRepository interface {
+	Create(ctx context.Context, session *domain.ImpersonationSession) error
+	FindByID(ctx context.Context, id int) (*domain.ImpersonationSession, error)
+	List(ctx context.Context, limit int) ([]domain.ImpersonationSession, error)
+	End(ctx context.Context, id, endedBy int) (bool, error)
+}
+
+type PostgresImpersonationRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresImpersonationRepository(db *gorm.DB) *PostgresImpersonationRepository {
+	return &PostgresImpersonationRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresImpersonationRepository) Create(ctx context.Context, session *domain.ImpersonationSession) error {
+	result := r.db.WithContext(ctx).Create(session)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record impersonation session: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresImpersonationRepository) FindByID(ctx context.Context, id int) (*domain.ImpersonationSession, error) {
+	var session domain.ImpersonationSession
+
+	result := r.db.WithContext(ctx).First(&session, id)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get impersonation session: %w", result.Error)
+	}
+
+	return &session, nil
+}
+
+func (r *PostgresImpersonationRepository) List(ctx context.Context, limit int) ([]domain.ImpersonationSession, error) {
+	var sessions []domain.ImpersonationSession
+
+	result := r.db.WithContext(ctx).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&sessions)
+
+	return sessions, result.Error
+}
+
+// End marks a session that has not ended or expired yet as ended, and
+// reports whether there was such a session.
+func (r *PostgresImpersonationRepository) End(ctx context.Context, id, endedBy int) (bool, error) {
+	now := time.Now().UTC()
+
+	result := r.db.WithContext(ctx).Model(&domain.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL AND expires_at > ?", id, now).
+		Updates(map[string]any{"ended_at": now, "ended_by": endedBy})
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*domain.LoginAttempt, error)
+	RecordFailure(ctx context.Context, key string, windowStart time.Time) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	ListLocked(ctx context.Context) ([]domain.LoginAttempt, error)
+}
+
+type PostgresLoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresLoginAttemptRepository(db *gorm.DB) *PostgresLoginAttemptRepository {
+	return &PostgresLoginAttemptRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresLoginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+
+	result := r.db.WithContext(ctx).Where("key = ?", key).First(&attempt)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get login attempts: %w", result.Error)
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure increments the failure counter atomically and returns the new
+// value. Failures older than windowStart are forgotten.
+func (r *PostgresLoginAttemptRepository) RecordFailure(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	var failures int
+
+	result := r.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures`,
+		key, time.Now().UTC(), windowStart,
+	).Scan(&failures)
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", result.Error)
+	}
+
+	return failures, nil
+}
+
+func (r *PostgresLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	result := r.db.WithContext(ctx).Model(&domain.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until)
+
+	return result.Error
+}
+
+func (r *PostgresLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	result := r.db.WithContext(ctx).Where("key = ?", key).Delete(&domain.LoginAttempt{})
+	return result.Error
+}
+
+func (r *PostgresLoginAttemptRepository) ListLocked(ctx context.Context) ([]domain.LoginAttempt, error) {
+	var attempts []domain.LoginAttempt
+
+	result := r.db.WithContext(ctx).
+		Where("locked_until > ?", time.Now().UTC()).
+		Order("locked_until DESC").
+		Find(&attempts)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MagicLinkRepository interface {
+	Create(ctx context.Context, link *domain.MagicLink) error
+	CountSinceByEmail(ctx context.Context, email string, since time.Time) (int, error)
+	CountSinceByIP(ctx context.Context, ipAddress string, since time.Time) (int, error)
+	Consume(ctx context.Context, tokenID, bindingHash string) (*domain.MagicLink, error)
+	InvalidateByUser(ctx context.Context, userID int) error
+}
+
+type PostgresMagicLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresMagicLinkRepository(db *gorm.DB) *PostgresMagicLinkRepository {
+	return &PostgresMagicLinkRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresMagicLinkRepository) Create(ctx context.Context, link *domain.MagicLink) error {
+	result := r.db.WithContext(ctx).Create(link)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save magic link: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresMagicLinkRepository) CountSinceByEmail(ctx context.Context, email string, since time.Time) (int, error) {
+	var count int64
+
+	result := r.db.WithContext(ctx).Model(&domain.MagicLink{}).
+		Where("LOWER(email) = LOWER(?) AND created_at > ?", email, since).
+		Count(&count)
+
+	return int(count), result.Error
+}
+
+func (r *PostgresMagicLinkRepository) CountSinceByIP(ctx context.Context, ipAddress string, since time.Time) (int, error) {
+	var count int64
+
+	result := r.db.WithContext(ctx).Model(&domain.MagicLink{}).
+		Where("ip_address = ? AND created_at > ?", ipAddress, since).
+		Count(&count)
+
+	return int(count), result.Error
+}
+
+// Consume marks an unused, unexpired link as used in a single statement and
+// returns it, or nil when there is no such link. A link presented without the
+// binding of the device that requested it is left untouched, so a forwarded
+// link neither works nor burns the original.
+func (r *PostgresMagicLinkRepository) Consume(ctx context.Context, tokenID, bindingHash string) (*domain.MagicLink, error) {
+	var links []domain.MagicLink
+
+	now := time.Now().UTC()
+	result := r.db.WithContext(ctx).Model(&links).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}, {Name: "email"}}}).
+		Where("token_id = ? AND binding_hash = ? AND user_id IS NOT NULL AND used_at IS NULL AND expires_at > ?", tokenID, bindingHash, now).
+		Update("used_at", now)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	return &links[0], nil
+}
+
+func (r *PostgresMagicLinkRepository) InvalidateByUser(ctx context.Context, userID int) error {
+	result := r.db.WithContext(ctx).Model(&domain.MagicLink{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now().UTC())
+
+	return result.Error
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *domain.PasswordResetToken) error
+	FindValid(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error)
+	Consume(ctx context.Context, tokenHash string) (int, error)
+	InvalidateByUser(ctx context.Context, userID int) error
+}
+
+type PostgresPasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresPasswordResetRepository(db *gorm.DB) *PostgresPasswordResetRepository {
+	return &PostgresPasswordResetRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresPasswordResetRepository) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save password reset token: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresPasswordResetRepository) FindValid(ctx context.Context, tokenHash string) (*domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+
+	result := r.db.WithContext(ctx).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now().UTC()).
+		First(&token)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get password reset token: %w", result.Error)
+	}
+
+	return &token, nil
+}
+
+// Consume marks an unused, unexpired token as used in a single statement and
+// returns the owner id, or 0 when there is no such token.
+func (r *PostgresPasswordResetRepository) Consume(ctx context.Context, tokenHash string) (int, error) {
+	var tokens []domain.PasswordResetToken
+
+	now := time.Now().UTC()
+	result := r.db.WithContext(ctx).Model(&tokens).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}}}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		Update("used_at", now)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	return tokens[0].UserID, nil
+}
+
+func (r *PostgresPasswordResetRepository) InvalidateByUser(ctx context.Context, userID int) error {
+	result := r.db.WithContext(ctx).Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now().UTC())
+
+	return result.Error
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *domain.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+	MarkRotated(ctx context.Context, id int) (bool, error)
+}
+
+type PostgresRefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresRefreshTokenRepository(db *gorm.DB) *PostgresRefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	result := r.db.WithContext(ctx).Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save refresh token: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+
+	result := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get refresh token: %w", result.Error)
+	}
+
+	return &token, nil
+}
+
+// MarkRotated flags the token as used. It reports false when the token had
+// already been rotated or revoked, so two concurrent refreshes with the same
+// token cannot both succeed.
+func (r *PostgresRefreshTokenRepository) MarkRotated(ctx context.Context, id int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", id).
+		Update("rotated_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
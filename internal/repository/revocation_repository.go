@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeUserTokens(ctx context.Context, userID int, revokedAt time.Time) (int, error)
+	UserTokenGeneration(ctx context.Context, userID int) (int, error)
+	RevokeSession(ctx context.Context, sessionID int) error
+	IsSessionRevoked(ctx context.Context, sessionID int) (bool, error)
+}
+
+type PostgresRevocationRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresRevocationRepository(db *gorm.DB) *PostgresRevocationRepository {
+	return &PostgresRevocationRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresRevocationRepository) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	now := time.Now().UTC()
+
+	// Entries are only useful until the token would have expired anyway.
+	if err := r.db.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Delete(&domain.RevokedToken{}).Error; err != nil {
+		return fmt.Errorf("failed to purge revoked tokens: %w", err)
+	}
+
+	token := &domain.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: now,
+	}
+
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(token)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke token: %w", result.Error)
+	}
+
+	return nil
+}
+
+func (r *PostgresRevocationRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+
+	result := r.db.WithContext(ctx).Model(&domain.RevokedToken{}).
+		Where("jti = ?", jti).
+		Count(&count)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// RevokeUserTokens starts a new token generation for the user and returns
+// it. Users whose tokens were never revoked are in generation 0.
+func (r *PostgresRevocationRepository) RevokeUserTokens(ctx context.Context, userID int, revokedAt time.Time) (int, error) {
+	var generation int
+
+	result := r.db.WithContext(ctx).Raw(`
+		INSERT INTO user_token_revocations (user_id, revoked_before, generation)
+		VALUES (?, ?, 1)
+		ON CONFLICT (user_id) DO UPDATE SET
+			revoked_before = EXCLUDED.revoked_before,
+			generation = user_token_revocations.generation + 1
+		RETURNING generation`,
+		userID, revokedAt,
+	).Scan(&generation)
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke user tokens: %w", result.Error)
+	}
+
+	return generation, nil
+}
+
+func (r *PostgresRevocationRepository) UserTokenGeneration(ctx context.Context, userID int) (int, error) {
+	var revocation domain.UserTokenRevocation
+
+	result := r.db.WithContext(ctx).First(&revocation, "user_id = ?", userID)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return revocation.Generation, nil
+}
+
+// RevokeSession marks the session as revoked. Sessions are usually revoked
+// through SessionRepository together with their refresh tokens already, so
+// this only fills in the time when nothing did.
+func (r *PostgresRevocationRepository) RevokeSession(ctx context.Context, sessionID int) error {
+	result := r.db.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+
+	return nil
+}
+
+// IsSessionRevoked reports whether the session access tokens were issued for
+// has been revoked. A session that no longer exists counts as revoked.
+func (r *PostgresRevocationRepository) IsSessionRevoked(ctx context.Context, sessionID int) (bool, error) {
+	var session domain.Session
+
+	result := r.db.WithContext(ctx).Select("revoked_at").First(&session, sessionID)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return session.RevokedAt != nil, nil
+}
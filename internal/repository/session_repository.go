@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	GetByID(ctx context.Context, id int) (*domain.Session, error)
+	ListActiveByUser(ctx context.Context, userID int) ([]domain.Session, error)
+	ListByUser(ctx context.Context, userID int) ([]domain.Session, error)
+	Touch(ctx context.Context, id int, ipAddress string, expiresAt time.Time) error
+	Revoke(ctx context.Context, userID, sessionID int) (bool, error)
+	RevokeAllByUser(ctx context.Context, userID int) error
+}
+
+type PostgresSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresSessionRepository(db *gorm.DB) *PostgresSessionRepository {
+	return &PostgresSessionRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	result := r.db.WithContext(ctx).Create(session)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create session: %w", result.Error)
+	}
+	return nil
+}
+
+func (r *PostgresSessionRepository) GetByID(ctx context.Context, id int) (*domain.Session, error) {
+	var session domain.Session
+
+	result := r.db.WithContext(ctx).First(&session, id)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get session by id: %w", result.Error)
+	}
+
+	return &session, nil
+}
+
+func (r *PostgresSessionRepository) ListActiveByUser(ctx context.Context, userID int) ([]domain.Session, error) {
+	var sessions []domain.Session
+
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now().UTC()).
+		Order("last_used_at DESC").
+		Find(&sessions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return sessions, nil
+}
+
+// ListByUser returns every session of the user, ended ones included.
+func (r *PostgresSessionRepository) ListByUser(ctx context.Context, userID int) ([]domain.Session, error) {
+	var sessions []domain.Session
+
+	result := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&sessions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return sessions, nil
+}
+
+func (r *PostgresSessionRepository) Touch(ctx context.Context, id int, ipAddress string, expiresAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_used_at": time.Now().UTC(),
+			"ip_address":   ipAddress,
+			"expires_at":   expiresAt,
+		})
+
+	return result.Error
+}
+
+// Revoke ends a single session together with its refresh tokens. It reports
+// false when the session does not exist, belongs to another user or was
+// already revoked.
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, userID, sessionID int) (bool, error) {
+	revoked := false
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		result := tx.Model(&domain.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		revoked = result.RowsAffected == 1
+		if !revoked {
+			return nil
+		}
+
+		return tx.Model(&domain.RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now).Error
+	})
+
+	return revoked, err
+}
+
+func (r *PostgresSessionRepository) RevokeAllByUser(ctx context.Context, userID int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		if err := tx.Model(&domain.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&domain.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
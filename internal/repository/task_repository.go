@@ -3,16 +3,48 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 	"user-service/internal/domain"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TaskRepository interface {
 	CreateTask(ctx context.Context, task *domain.Task) error
 	GetTaskByID(ctx context.Context, id int) (*domain.Task, error)
-	CompleteTask(ctx context.Context, userTask *domain.UserTask) error
-	GetUserCompletedTasks(ctx context.Context, userID int) ([]domain.Task, error)
+	UpdateTask(ctx context.Context, task *domain.Task) error
+	ArchiveTask(ctx context.Context, id int) error
+	ListTasks(ctx context.Context, includeArchived bool) ([]domain.Task, error)
+	SubmitTask(ctx context.Context, submission *domain.UserTask) (bool, error)
+	SubmitAndCredit(ctx context.Context, submission *domain.UserTask, entry *domain.BalanceTransaction) (bool, error)
+	FindSubmission(ctx context.Context, userID, taskID int) (*domain.UserTask, error)
+	GetSubmission(ctx context.Context, id int) (*domain.UserTask, error)
+	ListSubmissions(ctx context.Context, filter SubmissionFilter) ([]domain.UserTask, error)
+	ReviewSubmission(ctx context.Context, submission *domain.UserTask) (bool, error)
+	ApproveAndCredit(ctx context.Context, submission *domain.UserTask, entry *domain.BalanceTransaction) (bool, error)
+	ListCatalog(ctx context.Context, userID int, filter TaskCatalogFilter) ([]domain.TaskWithStatus, error)
+	GetUserCompletedTasks(ctx context.Context, userID int, beforeAt time.Time, beforeID, limit int) ([]domain.UserTask, error)
+	ListUserCompletions(ctx context.Context, userID int) ([]domain.UserTask, error)
+}
+
+// TaskCatalogFilter selects active tasks for ListCatalog. Status is one of
+// the domain.TaskStatus values or empty for all tasks, AfterID is the last
+// task of the previous page.
+type TaskCatalogFilter struct {
+	Category string
+	Status   string
+	AfterID  int
+	Limit    int
+}
+
+// SubmissionFilter selects submissions for review in id order. AfterID is
+// the last submission of the previous page.
+type SubmissionFilter struct {
+	Status  string
+	AfterID int
+	Limit   int
 }
 
 type PostgresTaskRepository struct {
@@ -36,32 +68,260 @@ func (r *PostgresTaskRepository) GetTaskByID(ctx context.Context, id int) (*doma
 	result := r.db.WithContext(ctx).First(&task, id)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("task not found")
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get task by id: %w", result.Error)
 	}
 
-	return &task, result.Error
+	return &task, nil
 }
 
-func (r *PostgresTaskRepository) CompleteTask(ctx context.Context, userTask *domain.UserTask) error {
-	result := r.db.WithContext(ctx).Create(userTask)
+// UpdateTask saves the editable fields of the task.
+func (r *PostgresTaskRepository) UpdateTask(ctx context.Context, task *domain.Task) error {
+	result := r.db.WithContext(ctx).Model(task).
+		Select("title", "description", "category", "points", "verification_mode", "updated_at").
+		Updates(task)
 
 	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
-			return errors.New("task")
-		}
-		return result.Error
+		return fmt.Errorf("failed to update task: %w", result.Error)
 	}
 
 	return nil
 }
 
-func (r *PostgresTaskRepository) GetUserCompletedTasks(ctx context.Context, userID int) ([]domain.Task, error) {
+// ArchiveTask hides the task. Completions keep referencing it, so balances
+// and histories stay intact; archiving an archived task changes nothing.
+func (r *PostgresTaskRepository) ArchiveTask(ctx context.Context, id int) error {
+	now := time.Now().UTC()
+
+	result := r.db.WithContext(ctx).Model(&domain.Task{}).
+		Where("id = ? AND archived_at IS NULL", id).
+		Updates(map[string]any{"archived_at": now, "updated_at": now})
+
+	return result.Error
+}
+
+func (r *PostgresTaskRepository) ListTasks(ctx context.Context, includeArchived bool) ([]domain.Task, error) {
 	var tasks []domain.Task
 
+	query := r.db.WithContext(ctx).Order("id")
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+
+	result := query.Find(&tasks)
+
+	return tasks, result.Error
+}
+
+// SubmitTask records the submission of a task. A user has one submission
+// per task: a rejected one is overwritten by the new one, while a pending or
+// approved one is left alone and false is returned.
+func (r *PostgresTaskRepository) SubmitTask(ctx context.Context, submission *domain.UserTask) (bool, error) {
+	return submitTask(r.db.WithContext(ctx), submission)
+}
+
+// SubmitAndCredit stores an already approved submission and credits the
+// reward in one transaction, so a task is never completed without its
+// points or paid twice. It reports false, crediting nothing, when the task
+// was already submitted.
+func (r *PostgresTaskRepository) SubmitAndCredit(ctx context.Context, submission *domain.UserTask, entry *domain.BalanceTransaction) (bool, error) {
+	var submitted bool
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if submitted, err = submitTask(tx, submission); err != nil || !submitted {
+			return err
+		}
+
+		return applyBalance(tx, entry)
+	})
+
+	return submitted, err
+}
+
+func submitTask(db *gorm.DB, submission *domain.UserTask) (bool, error) {
+	result := db.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "task_id"}},
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: clause.Column{Table: "user_tasks", Name: "status"}, Value: domain.SubmissionRejected},
+			}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"status", "proof_text", "proof_url", "proof_file_key", "proof_file_name", "proof_content_type",
+				"submitted_at", "completed_at", "reviewed_by", "reviewed_at", "rejection_reason",
+			}),
+		}).
+		Create(submission)
+
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to submit task: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *PostgresTaskRepository) FindSubmission(ctx context.Context, userID, taskID int) (*domain.UserTask, error) {
+	var submission domain.UserTask
+
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND task_id = ?", userID, taskID).
+		First(&submission)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &submission, nil
+}
+
+// GetSubmission returns the submission with its task and user.
+func (r *PostgresTaskRepository) GetSubmission(ctx context.Context, id int) (*domain.UserTask, error) {
+	var submission domain.UserTask
+
+	result := r.db.WithContext(ctx).
+		Preload("Task").
+		Preload("User").
+		First(&submission, id)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &submission, nil
+}
+
+func (r *PostgresTaskRepository) ListSubmissions(ctx context.Context, filter SubmissionFilter) ([]domain.UserTask, error) {
+	var submissions []domain.UserTask
+
 	result := r.db.WithContext(ctx).
-		Joins("JOIN user_tasks WHERE user_tasks.task_id = tasks.id").
-		Where("user_tasks.user_id = ?", userID).
-		Find(&tasks)
+		Preload("Task").
+		Preload("User").
+		Where("status = ? AND id > ?", filter.Status, filter.AfterID).
+		Order("id").
+		Limit(filter.Limit).
+		Find(&submissions)
+
+	return submissions, result.Error
+}
+
+// ReviewSubmission stores the decision on a pending submission. It reports
+// false when the submission is no longer pending, so a submission is decided
+// once even if two moderators review it at the same time.
+func (r *PostgresTaskRepository) ReviewSubmission(ctx context.Context, submission *domain.UserTask) (bool, error) {
+	return reviewSubmission(r.db.WithContext(ctx), submission)
+}
+
+// ApproveAndCredit records the approval and credits the reward in one
+// transaction. It reports false, crediting nothing, when the submission is
+// no longer pending.
+func (r *PostgresTaskRepository) ApproveAndCredit(ctx context.Context, submission *domain.UserTask, entry *domain.BalanceTransaction) (bool, error) {
+	var reviewed bool
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if reviewed, err = reviewSubmission(tx, submission); err != nil || !reviewed {
+			return err
+		}
+
+		return applyBalance(tx, entry)
+	})
+
+	return reviewed, err
+}
+
+func reviewSubmission(db *gorm.DB, submission *domain.UserTask) (bool, error) {
+	result := db.Model(&domain.UserTask{}).
+		Where("id = ? AND status = ?", submission.ID, domain.SubmissionPending).
+		Updates(map[string]any{
+			"status":           submission.Status,
+			"completed_at":     submission.CompletedAt,
+			"reviewed_by":      submission.ReviewedBy,
+			"reviewed_at":      submission.ReviewedAt,
+			"rejection_reason": submission.RejectionReason,
+		})
+
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to review submission: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// ListCatalog returns active tasks in id order, each with the status of the
+// user's submission and the time it was approved, if there is one. A task
+// whose submission was rejected is available again.
+func (r *PostgresTaskRepository) ListCatalog(ctx context.Context, userID int, filter TaskCatalogFilter) ([]domain.TaskWithStatus, error) {
+	var tasks []domain.TaskWithStatus
+
+	query := r.db.WithContext(ctx).Table("tasks").
+		Select("tasks.*, user_tasks.status AS submission_status, user_tasks.completed_at").
+		Joins("LEFT JOIN user_tasks ON user_tasks.task_id = tasks.id AND user_tasks.user_id = ?", userID).
+		Where("tasks.archived_at IS NULL AND tasks.id > ?", filter.AfterID)
+
+	if filter.Category != "" {
+		query = query.Where("tasks.category = ?", filter.Category)
+	}
+
+	switch filter.Status {
+	case domain.TaskStatusCompleted:
+		query = query.Where("user_tasks.status = ?", domain.SubmissionApproved)
+	case domain.TaskStatusPending:
+		query = query.Where("user_tasks.status = ?", domain.SubmissionPending)
+	case domain.TaskStatusAvailable:
+		query = query.Where("(user_tasks.id IS NULL OR user_tasks.status = ?)", domain.SubmissionRejected)
+	}
+
+	result := query.Order("tasks.id").Limit(filter.Limit).Find(&tasks)
 
 	return tasks, result.Error
 }
+
+// GetUserCompletedTasks returns the submissions of the user in every status
+// with their tasks, archived ones included, most recently submitted first. A
+// resubmission keeps the row and id of the rejected one, so pages are cut by
+// (submitted_at, id): with beforeID > 0 it continues after the submission of
+// that id, submitted at beforeAt.
+func (r *PostgresTaskRepository) GetUserCompletedTasks(ctx context.Context, userID int, beforeAt time.Time, beforeID, limit int) ([]domain.UserTask, error) {
+	var completions []domain.UserTask
+
+	query := r.db.WithContext(ctx).
+		Preload("Task").
+		Where("user_id = ?", userID)
+
+	if beforeID > 0 {
+		query = query.Where("(submitted_at, id) < (?, ?)", beforeAt, beforeID)
+	}
+
+	result := query.Order("submitted_at DESC, id DESC").Limit(limit).Find(&completions)
+
+	return completions, result.Error
+}
+
+// ListUserCompletions returns the submissions of the user together with
+// their tasks, oldest first.
+func (r *PostgresTaskRepository) ListUserCompletions(ctx context.Context, userID int) ([]domain.UserTask, error) {
+	var completions []domain.UserTask
+
+	result := r.db.WithContext(ctx).
+		Preload("Task").
+		Where("user_id = ?", userID).
+		Order("submitted_at, id").
+		Find(&completions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return completions, nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwoFactorRepository interface {
+	GetTOTP(ctx context.Context, userID int) (*domain.UserTOTP, error)
+	SavePendingTOTP(ctx context.Context, userID int, secretEncrypted string) error
+	ConfirmTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID int) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
+
+type PostgresTwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresTwoFactorRepository(db *gorm.DB) *PostgresTwoFactorRepository {
+	return &PostgresTwoFactorRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresTwoFactorRepository) GetTOTP(ctx context.Context, userID int) (*domain.UserTOTP, error) {
+	var totp domain.UserTOTP
+
+	result := r.db.WithContext(ctx).First(&totp, "user_id = ?", userID)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get TOTP settings: %w", result.Error)
+	}
+
+	return &totp, nil
+}
+
+// SavePendingTOTP stores a new, not yet confirmed secret, replacing any
+// earlier unconfirmed enrollment. A confirmed secret is never overwritten.
+func (r *PostgresTwoFactorRepository) SavePendingTOTP(ctx context.Context, userID int, secretEncrypted string) error {
+	totp := &domain.UserTOTP{
+		UserID:          userID,
+		SecretEncrypted: secretEncrypted,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret_encrypted", "created_at", "last_used_step"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "user_totp.confirmed_at IS NULL"},
+		}},
+	}).Create(totp)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save TOTP secret: %w", result.Error)
+	}
+
+	return nil
+}
+
+func (r *PostgresTwoFactorRepository) ConfirmTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.UserTOTP{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]interface{}{
+				"confirmed_at":   time.Now().UTC(),
+				"last_used_step": step,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("no pending TOTP enrollment")
+		}
+
+		return replaceRecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+}
+
+// UseTOTPStep records the step of an accepted code. It reports false if that
+// step or a later one was already used, which rejects replayed codes.
+func (r *PostgresTwoFactorRepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *PostgresTwoFactorRepository) DeleteTOTP(ctx context.Context, userID int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&domain.UserTOTP{}).Error
+	})
+}
+
+func (r *PostgresTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func (r *PostgresTwoFactorRepository) ConsumeRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *PostgresTwoFactorRepository) CountUnusedRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int64
+
+	result := r.db.WithContext(ctx).Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count)
+
+	return int(count), result.Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]domain.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = domain.RecoveryCode{UserID: userID, CodeHash: hash, CreatedAt: time.Now().UTC()}
+	}
+
+	if len(codes) == 0 {
+		return nil
+	}
+
+	return tx.Create(&codes).Error
+}
@@ -4,22 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 	"user-service/internal/domain"
+	"user-service/internal/username"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) (int, error)
 	FindByUsername(ctx context.Context, username string) (*domain.User, error)
+	FindByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetUserById(ctx context.Context, id int) (*domain.User, error)
-	UpdateBalance(ctx context.Context, userID int, newBalance int) error
-	GetTopUsersByBalance(ctx context.Context, limit int) ([]domain.User, error)
-	AddReferrer(ctx context.Context, userID, referrerID int) error
-
-	SaveRefreshToken(ctx context.Context, userID int, token string) error
-	FindByRefreshToken(ctx context.Context, token string) (*domain.User, error)
-	RevokeRefreshToken(ctx context.Context, userID int) error
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+	GetTopUsersByBalance(ctx context.Context, limit int, verifiedOnly bool) ([]domain.User, error)
+	AddReferrerAndCredit(ctx context.Context, userID, referrerID int, bonus *domain.BalanceTransaction) error
+	UpdateRole(ctx context.Context, userID int, role string) error
+	SetBanned(ctx context.Context, userID int, bannedAt *time.Time) error
+	ExistsWithRole(ctx context.Context, role string) (bool, error)
+	UpdateEmail(ctx context.Context, userID int, email *string) error
+	MarkEmailVerified(ctx context.Context, userID int, email string) (bool, error)
+	ListReferrals(ctx context.Context, referrerID int) ([]domain.User, error)
+	ScheduleDeletion(ctx context.Context, userID int, deletedAt, purgeAfter time.Time) (bool, error)
+	CancelDeletion(ctx context.Context, userID int, now time.Time) (bool, error)
+	PurgeDeleted(ctx context.Context, now time.Time) (int64, []string, error)
 }
 
 type PostgresUserRepository struct {
@@ -32,18 +41,23 @@ func NewPostgresUserRepository(db *gorm.DB) *PostgresUserRepository {
 	}
 }
 
+// Create inserts the user. A taken username or email is reported as a
+// *ConflictError.
 func (r *PostgresUserRepository) Create(ctx context.Context, user *domain.User) (int, error) {
 	result := r.db.WithContext(ctx).Create(user)
 	if result.Error != nil {
-		return 0, fmt.Errorf("failed to create new user: %w", result.Error)
+		return 0, fmt.Errorf("failed to create new user: %w", asConflict(result.Error))
 	}
 	return int(user.ID), nil
 }
 
-func (r *PostgresUserRepository) FindByUsername(ctx context.Context, username string) (*domain.User, error) {
+// FindByUsername looks the name up in its canonical form, the form every
+// stored name is in. Comparing through LOWER lets the lookup use the unique
+// index.
+func (r *PostgresUserRepository) FindByUsername(ctx context.Context, name string) (*domain.User, error) {
 	var user domain.User
 
-	result := r.db.WithContext(ctx).Where("username = ?", username).First(&user)
+	result := r.db.WithContext(ctx).Where("LOWER(username) = ?", username.Canonical(name)).First(&user)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, nil
@@ -56,6 +70,22 @@ func (r *PostgresUserRepository) FindByUsername(ctx context.Context, username st
 	return &user, nil
 }
 
+func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	var user domain.User
+
+	result := r.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).First(&user)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get user by email: %w", result.Error)
+	}
+
+	return &user, nil
+}
+
 func (r *PostgresUserRepository) GetUserById(ctx context.Context, id int) (*domain.User, error) {
 	var user domain.User
 
@@ -72,10 +102,10 @@ func (r *PostgresUserRepository) GetUserById(ctx context.Context, id int) (*doma
 	return &user, nil
 }
 
-func (r *PostgresUserRepository) SaveRefreshToken(ctx context.Context, userID int, token string) error {
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
 	result := r.db.WithContext(ctx).Model(&domain.User{}).
 		Where("id = ?", userID).
-		Update("refresh_token", token)
+		Update("password_hash", passwordHash)
 
 	if result.Error != nil {
 		return result.Error
@@ -88,38 +118,84 @@ func (r *PostgresUserRepository) SaveRefreshToken(ctx context.Context, userID in
 	return nil
 }
 
-func (r *PostgresUserRepository) FindByRefreshToken(ctx context.Context, token string) (*domain.User, error) {
-	var user domain.User
-
-	result := r.db.WithContext(ctx).Where("refresh_token = ?", token).First(&user)
+// GetTopUsersByBalance returns the richest users. With verifiedOnly users
+// without a verified email are left out. Deleted and guest accounts never
+// show up.
+func (r *PostgresUserRepository) GetTopUsersByBalance(ctx context.Context, limit int, verifiedOnly bool) ([]domain.User, error) {
+	var users []domain.User
 
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("user by token not found, may be token is invalid")
+	query := r.db.WithContext(ctx).Where("deleted_at IS NULL AND NOT is_guest")
+	if verifiedOnly {
+		query = query.Where("email IS NOT NULL AND email_verified_at IS NOT NULL")
 	}
 
+	result := query.
+		Order("balance DESC").
+		Limit(limit).
+		Find(&users)
+
 	if result.Error != nil {
-		return nil, fmt.Errorf("error to get user by id: %w", result.Error)
+		return nil, result.Error
 	}
 
-	return &user, nil
+	return users, nil
+}
+
+// AddReferrerAndCredit sets the referrer of a user that has none yet and
+// credits the referral bonus in the same transaction. The referrer is only
+// written while it is still empty, so concurrent requests cannot both set it
+// and pay the bonus twice.
+func (r *PostgresUserRepository) AddReferrerAndCredit(ctx context.Context, userID, referrerID int, bonus *domain.BalanceTransaction) error {
+	if userID == referrerID {
+		return errors.New("you cannot be a referal for yourself")
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var referrer domain.User
+		if err := tx.Where("deleted_at IS NULL").First(&referrer, referrerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("referrer not found")
+			}
+			return err
+		}
+
+		result := tx.Model(&domain.User{}).
+			Where("id = ? AND referrer_id IS NULL", userID).
+			Update("referrer_id", referrerID)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected != 1 {
+			return errors.New("the user already has a referrer")
+		}
+
+		return applyBalance(tx, bonus)
+	})
 }
 
-func (r *PostgresUserRepository) RevokeRefreshToken(ctx context.Context, userID int) error {
+func (r *PostgresUserRepository) UpdateRole(ctx context.Context, userID int, role string) error {
 	result := r.db.WithContext(ctx).Model(&domain.User{}).
 		Where("id = ?", userID).
-		Update("refresh_token", nil)
+		Update("role", role)
 
 	if result.Error != nil {
 		return result.Error
 	}
 
+	if result.RowsAffected == 0 {
+		return errors.New("user is not found")
+	}
+
 	return nil
 }
 
-func (r *PostgresUserRepository) UpdateBalance(ctx context.Context, userID int, newBalance int) error {
+// SetBanned bans the user at bannedAt, or lifts the ban when it is nil.
+func (r *PostgresUserRepository) SetBanned(ctx context.Context, userID int, bannedAt *time.Time) error {
 	result := r.db.WithContext(ctx).Model(&domain.User{}).
 		Where("id = ?", userID).
-		Update("balance", newBalance)
+		Update("banned_at", bannedAt)
 
 	if result.Error != nil {
 		return result.Error
@@ -132,12 +208,55 @@ func (r *PostgresUserRepository) UpdateBalance(ctx context.Context, userID int,
 	return nil
 }
 
-func (r *PostgresUserRepository) GetTopUsersByBalance(ctx context.Context, limit int) ([]domain.User, error) {
+func (r *PostgresUserRepository) ExistsWithRole(ctx context.Context, role string) (bool, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&domain.User{}).Where("role = ?", role).Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// UpdateEmail replaces the address of the user. The new address is not
+// verified yet, so the verification mark is cleared together with it.
+func (r *PostgresUserRepository) UpdateEmail(ctx context.Context, userID int, email *string) error {
+	result := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]any{"email": email, "email_verified_at": nil})
+
+	if result.Error != nil {
+		return asConflict(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("user is not found")
+	}
+
+	return nil
+}
+
+// MarkEmailVerified marks the address as verified if it is still the current
+// address of the user, and reports whether it was.
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, userID int, email string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ? AND LOWER(email) = LOWER(?)", userID, email).
+		Update("email_verified_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// ListReferrals returns the users who named referrerID as their referrer.
+func (r *PostgresUserRepository) ListReferrals(ctx context.Context, referrerID int) ([]domain.User, error) {
 	var users []domain.User
 
 	result := r.db.WithContext(ctx).
-		Order("balance DESC").
-		Limit(limit).
+		Where("referrer_id = ?", referrerID).
+		Order("id").
 		Find(&users)
 
 	if result.Error != nil {
@@ -147,31 +266,67 @@ func (r *PostgresUserRepository) GetTopUsersByBalance(ctx context.Context, limit
 	return users, nil
 }
 
-func (r *PostgresUserRepository) AddReferrer(ctx context.Context, userID, referrerID int) error {
-	var user domain.User
-	if err := r.db.WithContext(ctx).First(&user, userID).Error; err != nil {
-		return err
-	}
+// ScheduleDeletion marks the account as deleted, to be purged after
+// purgeAfter. Nothing else changes until then, so the deletion can still be
+// cancelled. It reports false when the account is already marked.
+func (r *PostgresUserRepository) ScheduleDeletion(ctx context.Context, userID int, deletedAt, purgeAfter time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ? AND deleted_at IS NULL", userID).
+		Updates(map[string]any{
+			"deleted_at":  deletedAt,
+			"purge_after": purgeAfter,
+		})
 
-	if user.ReferrerID != nil {
-		return errors.New("the user already has a referrer")
-	}
+	return result.RowsAffected > 0, result.Error
+}
 
-	if userID == referrerID {
-		return errors.New("you cannot be a referal for yourself")
-	}
+// CancelDeletion clears the deletion mark of an account whose grace period
+// is not over yet. It reports false when there is nothing to cancel.
+func (r *PostgresUserRepository) CancelDeletion(ctx context.Context, userID int, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL AND purge_after > ?", userID, now).
+		Updates(map[string]any{
+			"deleted_at":  nil,
+			"purge_after": nil,
+		})
+
+	return result.RowsAffected > 0, result.Error
+}
 
-	var referrer domain.User
-	if err := r.db.WithContext(ctx).First(&referrer, referrerID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("referrer not found")
+// PurgeDeleted removes deleted accounts whose grace period is over and
+// returns how many were removed along with the keys of the proof files their
+// submissions referenced, so the caller can remove the files. Their remaining
+// rows go with them through the foreign keys; ledger entries and referrals
+// only lose the link. The accounts are locked first, so a cancellation racing
+// the purge either wins or finds nothing left.
+func (r *PostgresUserRepository) PurgeDeleted(ctx context.Context, now time.Time) (int64, []string, error) {
+	var purged int64
+	var proofKeys []string
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []int
+		err := tx.Model(&domain.User{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND purge_after <= ?", now).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
 		}
-		return err
-	}
 
-	result := r.db.WithContext(ctx).Model(&domain.User{}).
-		Where("id = ?", userID).
-		Update("referrer_id", referrerID)
+		err = tx.Model(&domain.UserTask{}).
+			Where("user_id IN ? AND proof_file_key <> ''", ids).
+			Pluck("proof_file_key", &proofKeys).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("id IN ?", ids).Delete(&domain.User{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, nil, err
+	}
 
-	return result.Error
+	return purged, proofKeys, nil
 }
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebAuthnRepository interface {
+	CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challengeHash, ceremony string) (*domain.WebAuthnChallenge, error)
+	CreateCredential(ctx context.Context, credential *domain.WebAuthnCredential) error
+	FindCredential(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error)
+	RecordUse(ctx context.Context, id int, previousCount, signCount int64, backedUp bool) (bool, error)
+	DeleteCredential(ctx context.Context, userID, id int) (bool, error)
+}
+
+type PostgresWebAuthnRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresWebAuthnRepository(db *gorm.DB) *PostgresWebAuthnRepository {
+	return &PostgresWebAuthnRepository{
+		db: db,
+	}
+}
+
+// CreateChallenge stores a new ceremony and drops the expired ones, so the
+// table only ever holds ceremonies that can still finish.
+func (r *PostgresWebAuthnRepository) CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", time.Now().UTC()).Delete(&domain.WebAuthnChallenge{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(challenge).Error; err != nil {
+			return fmt.Errorf("failed to save WebAuthn challenge: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ConsumeChallenge deletes an unexpired challenge of the ceremony and returns
+// it, or nil when there is none, so every challenge is answered at most once.
+func (r *PostgresWebAuthnRepository) ConsumeChallenge(ctx context.Context, challengeHash, ceremony string) (*domain.WebAuthnChallenge, error) {
+	var challenges []domain.WebAuthnChallenge
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("challenge_hash = ? AND ceremony = ? AND expires_at > ?", challengeHash, ceremony, time.Now().UTC()).
+		Delete(&challenges)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(challenges) == 0 {
+		return nil, nil
+	}
+
+	return &challenges[0], nil
+}
+
+func (r *PostgresWebAuthnRepository) CreateCredential(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	result := r.db.WithContext(ctx).Create(credential)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save passkey: %w", asConflict(result.Error))
+	}
+	return nil
+}
+
+func (r *PostgresWebAuthnRepository) FindCredential(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+
+	result := r.db.WithContext(ctx).Where("credential_id = ?", credentialID).First(&credential)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("error to get passkey: %w", result.Error)
+	}
+
+	return &credential, nil
+}
+
+func (r *PostgresWebAuthnRepository) ListCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error) {
+	var credentials []domain.WebAuthnCredential
+
+	result := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&credentials)
+
+	return credentials, result.Error
+}
+
+// RecordUse stores the counter of a successful sign in, but only if nobody
+// else has moved it since previousCount was read. Two sign ins racing with
+// the same counter cannot both succeed.
+func (r *PostgresWebAuthnRepository) RecordUse(ctx context.Context, id int, previousCount, signCount int64, backedUp bool) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&domain.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previousCount).
+		Updates(map[string]any{
+			"sign_count":   signCount,
+			"backed_up":    backedUp,
+			"last_used_at": time.Now().UTC(),
+		})
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *PostgresWebAuthnRepository) DeleteCredential(ctx context.Context, userID, id int) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		Delete(&domain.WebAuthnCredential{})
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/password"
+	"user-service/internal/proofstore"
+	"user-service/internal/repository"
+)
+
+var (
+	ErrDeletionScheduled        = errors.New("account is already scheduled for deletion")
+	ErrDeletionNotScheduled     = errors.New("account is not scheduled for deletion")
+	ErrReauthenticationRequired = errors.New("sign in again or send a two-factor code to confirm")
+)
+
+// reauthWindow is how recently a session must have been signed in to confirm
+// the deletion of an account without a password.
+const reauthWindow = 10 * time.Minute
+
+// AccountService lets users take out their personal data and delete their
+// account.
+type AccountService struct {
+	userRepo         repository.UserRepository
+	taskRepo         repository.TaskRepository
+	balanceRepo      repository.BalanceRepository
+	sessionRepo      repository.SessionRepository
+	identityRepo     repository.IdentityRepository
+	authService      *AuthService
+	apiKeyService    *APIKeyService
+	twoFactorService *TwoFactorService
+	hasher           password.Hasher
+	proofs           proofstore.Store
+	gracePeriod      time.Duration
+}
+
+func NewAccountService(
+	cfg config.AccountConfig,
+	userRepo repository.UserRepository,
+	taskRepo repository.TaskRepository,
+	balanceRepo repository.BalanceRepository,
+	sessionRepo repository.SessionRepository,
+	identityRepo repository.IdentityRepository,
+	authService *AuthService,
+	apiKeyService *APIKeyService,
+	twoFactorService *TwoFactorService,
+	hasher password.Hasher,
+	proofs proofstore.Store,
+) *AccountService {
+	return &AccountService{
+		userRepo:         userRepo,
+		taskRepo:         taskRepo,
+		balanceRepo:      balanceRepo,
+		sessionRepo:      sessionRepo,
+		identityRepo:     identityRepo,
+		authService:      authService,
+		apiKeyService:    apiKeyService,
+		twoFactorService: twoFactorService,
+		hasher:           hasher,
+		proofs:           proofs,
+		gracePeriod:      cfg.DeletionGracePeriod,
+	}
+}
+
+// Export collects the profile of the user together with their balance
+// history, completed tasks, referrals, sessions, linked identities and API
+// keys. Secrets such as password and key hashes are never included.
+func (s *AccountService) Export(ctx context.Context, userID int) (*dto.AccountExport, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	twoFactor, err := s.twoFactorService.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.balanceRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load balance history: %w", err)
+	}
+
+	completions, err := s.taskRepo.ListUserCompletions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load completed tasks: %w", err)
+	}
+
+	referrals, err := s.userRepo.ListReferrals(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load referrals: %w", err)
+	}
+
+	sessions, err := s.sessionRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	identities, err := s.identityRepo.ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identities: %w", err)
+	}
+
+	apiKeys, err := s.apiKeyService.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &dto.AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: dto.ExportProfile{
+			ID:              user.ID,
+			Username:        user.Username,
+			Email:           user.Email,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			Role:            user.Role,
+			Guest:           user.IsGuest,
+			Balance:         user.Balance,
+			ReferrerID:      user.ReferrerID,
+			TwoFactor:       twoFactor,
+		},
+		BalanceHistory: make([]dto.BalanceTransactionResponse, len(entries)),
+		CompletedTasks: make([]dto.CompletedTaskResponse, len(completions)),
+		Referrals:      make([]dto.ReferralResponse, 0, len(referrals)),
+		Sessions:       make([]dto.ExportSession, len(sessions)),
+		Identities:     make([]dto.IdentityResponse, len(identities)),
+		APIKeys:        apiKeys,
+	}
+
+	for i := range entries {
+		export.BalanceHistory[i] = dto.ToBalanceTransactionResponse(&entries[i])
+	}
+
+	for i := range completions {
+		export.CompletedTasks[i] = dto.ToCompletedTaskResponse(&completions[i])
+	}
+
+	for _, referral := range referrals {
+		if referral.Deleted() {
+			continue
+		}
+		export.Referrals = append(export.Referrals, dto.ReferralResponse{ID: referral.ID, Username: referral.Username})
+	}
+
+	for i := range sessions {
+		export.Sessions[i] = dto.ToExportSession(&sessions[i])
+	}
+
+	for i := range identities {
+		export.Identities[i] = dto.ToIdentityResponse(&identities[i])
+	}
+
+	return export, nil
+}
+
+// Delete schedules the account for purging after the grace period. Until
+// then nothing is removed: the user is signed out everywhere, API keys stop
+// working and the account is hidden from others, but signing in again and
+// calling Restore brings it back as it was.
+func (s *AccountService) Delete(ctx context.Context, userID, sessionID int, req dto.DeleteAccountRequest) (*dto.AccountDeletionResponse, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.Deleted() {
+		return nil, ErrDeletionScheduled
+	}
+
+	if err := s.confirmDeletion(ctx, user, sessionID, req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	purgeAfter := now.Add(s.gracePeriod)
+
+	scheduled, err := s.userRepo.ScheduleDeletion(ctx, userID, now, purgeAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	if !scheduled {
+		return nil, ErrDeletionScheduled
+	}
+
+	if err := s.authService.RevokeAllUserAccess(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("account deleted: user=%d purge_after=%s", userID, purgeAfter.Format(time.RFC3339))
+
+	return &dto.AccountDeletionResponse{
+		DeletedAt:  now,
+		PurgeAfter: purgeAfter,
+	}, nil
+}
+
+// confirmDeletion makes sure the owner asks for the deletion, not just
+// someone holding a token. Accounts with a password confirm it. The others
+// send a two-factor code, or without one must have signed in to the current
+// session within reauthWindow.
+func (s *AccountService) confirmDeletion(ctx context.Context, user *domain.User, sessionID int, req dto.DeleteAccountRequest) error {
+	if user.PasswordHash != "" {
+		if ok, _ := s.hasher.Verify(user.PasswordHash, req.Password); !ok {
+			return ErrWrongPassword
+		}
+		return nil
+	}
+
+	if req.Code != "" {
+		return s.twoFactorService.Verify(ctx, user.ID, req.Code)
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if session == nil || session.UserID != user.ID || time.Since(session.CreatedAt) > reauthWindow {
+		return ErrReauthenticationRequired
+	}
+
+	return nil
+}
+
+// Restore cancels the deletion of the account while its grace period lasts.
+func (s *AccountService) Restore(ctx context.Context, userID int) error {
+	restored, err := s.userRepo.CancelDeletion(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to restore account: %w", err)
+	}
+
+	if !restored {
+		return ErrDeletionNotScheduled
+	}
+
+	log.Printf("account restored: user=%d", userID)
+	return nil
+}
+
+// PurgeDeleted removes the accounts whose grace period is over together with
+// the proofs attached to their task submissions.
+func (s *AccountService) PurgeDeleted(ctx context.Context) (int64, error) {
+	purged, proofKeys, err := s.userRepo.PurgeDeleted(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range proofKeys {
+		deleteProof(ctx, s.proofs, key)
+	}
+
+	return purged, nil
+}
+
+// RunPurger purges deleted accounts every interval until ctx is done.
+func (s *AccountService) RunPurger(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, interval, "purge of deleted accounts", s.PurgeDeleted)
+}
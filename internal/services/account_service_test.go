@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+)
+
+type accountFixture struct {
+	service  *AccountService
+	users    *fakeUserRepo
+	sessions *fakeSessionRepo
+	user     *domain.User
+}
+
+// newAccountFixture sets up a user without a password, as created through an
+// identity provider, whose only session was signed in age ago.
+func newAccountFixture(t *testing.T, age time.Duration) *accountFixture {
+	t.Helper()
+
+	user := &domain.User{ID: 1, Username: "alice"}
+	users := newFakeUserRepo(user)
+	authService, sessions := newTestAuthService(t, users)
+	sessions.sessions = append(sessions.sessions, domain.Session{ID: 1, UserID: user.ID, CreatedAt: time.Now().UTC().Add(-age)})
+
+	service := NewAccountService(
+		config.AccountConfig{DeletionGracePeriod: 24 * time.Hour},
+		users,
+		nil,
+		nil,
+		sessions,
+		nil,
+		authService,
+		nil,
+		NewTwoFactorService(&fakeTwoFactorRepo{}, nil, "user-service-test"),
+		nil,
+		nil,
+	)
+
+	return &accountFixture{service: service, users: users, sessions: sessions, user: user}
+}
+
+func TestDeletePasswordlessAccountRequiresRecentSignIn(t *testing.T) {
+	f := newAccountFixture(t, time.Hour)
+
+	_, err := f.service.Delete(context.Background(), f.user.ID, 1, dto.DeleteAccountRequest{})
+	if !errors.Is(err, ErrReauthenticationRequired) {
+		t.Fatalf("got %v, want ErrReauthenticationRequired", err)
+	}
+
+	if f.user.Deleted() || f.sessions.sessions[0].RevokedAt != nil {
+		t.Fatal("account was deleted with a stale session")
+	}
+}
+
+func TestDeletePasswordlessAccountWithoutTwoFactorRejectsCode(t *testing.T) {
+	f := newAccountFixture(t, time.Minute)
+
+	_, err := f.service.Delete(context.Background(), f.user.ID, 1, dto.DeleteAccountRequest{Code: "123456"})
+	if !errors.Is(err, ErrTwoFactorNotEnabled) {
+		t.Fatalf("got %v, want ErrTwoFactorNotEnabled", err)
+	}
+
+	if f.user.Deleted() {
+		t.Fatal("account was deleted with a code it cannot have")
+	}
+}
+
+func TestDeleteKeepsAccountRestorable(t *testing.T) {
+	f := newAccountFixture(t, time.Minute)
+	ctx := context.Background()
+
+	response, err := f.service.Delete(ctx, f.user.ID, 1, dto.DeleteAccountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !f.user.Deleted() || !f.user.PurgeAfter.Equal(response.PurgeAfter) {
+		t.Fatalf("account is not scheduled for deletion: %+v", f.user)
+	}
+
+	if f.user.Username != "alice" {
+		t.Fatalf("account data changed before the purge: %+v", f.user)
+	}
+
+	if f.sessions.sessions[0].RevokedAt == nil {
+		t.Fatal("session survived the deletion")
+	}
+
+	if _, err := f.service.Delete(ctx, f.user.ID, 1, dto.DeleteAccountRequest{}); !errors.Is(err, ErrDeletionScheduled) {
+		t.Fatalf("second deletion: got %v, want ErrDeletionScheduled", err)
+	}
+
+	if err := f.service.Restore(ctx, f.user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.user.Deleted() {
+		t.Fatal("account is still scheduled for deletion")
+	}
+
+	if err := f.service.Restore(ctx, f.user.ID); !errors.Is(err, ErrDeletionNotScheduled) {
+		t.Fatalf("second restore: got %v, want ErrDeletionNotScheduled", err)
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/password"
+	"user-service/internal/rbac"
+	"user-service/internal/repository"
+	"user-service/internal/username"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInvalidRole         = errors.New("invalid role")
+	ErrCannotModifySelf    = errors.New("you cannot change your own role or ban yourself")
+	ErrInsufficientRank    = errors.New("you can only manage users with a lower role than yours")
+	ErrBootstrapNoPassword = errors.New("BOOTSTRAP_ADMIN_PASSWORD is required to create the bootstrap admin")
+	ErrImpersonationEnded  = errors.New("impersonation session not found or already over")
+)
+
+const impersonationListLimit = 100
+
+// Actor is the signed in user performing an administrative action.
+type Actor struct {
+	UserID int
+	Role   string
+}
+
+type AdminService struct {
+	userRepo          repository.UserRepository
+	impersonationRepo repository.ImpersonationRepository
+	jwtService        *JWTService
+	impersonationTTL  time.Duration
+	authService       *AuthService
+	apiKeyService     *APIKeyService
+	revocationService *RevocationService
+	throttler         *LoginThrottler
+	policy            *password.Policy
+	hasher            password.Hasher
+}
+
+func NewAdminService(
+	cfg config.ImpersonationConfig,
+	userRepo repository.UserRepository,
+	impersonationRepo repository.ImpersonationRepository,
+	jwtService *JWTService,
+	authService *AuthService,
+	apiKeyService *APIKeyService,
+	revocationService *RevocationService,
+	throttler *LoginThrottler,
+	policy *password.Policy,
+	hasher password.Hasher,
+) *AdminService {
+	return &AdminService{
+		userRepo:          userRepo,
+		impersonationRepo: impersonationRepo,
+		jwtService:        jwtService,
+		impersonationTTL:  cfg.TokenTTL,
+		authService:       authService,
+		apiKeyService:     apiKeyService,
+		revocationService: revocationService,
+		throttler:         throttler,
+		policy:            policy,
+		hasher:            hasher,
+	}
+}
+
+func (s *AdminService) ListLockouts(ctx context.Context) ([]dto.LockoutResponse, error) {
+	return s.throttler.ListLockouts(ctx)
+}
+
+func (s *AdminService) Unlock(ctx context.Context, key string) error {
+	return s.throttler.Unlock(ctx, key)
+}
+
+// BanUser blocks logins of the user and cuts off every session, access token
+// and API key they have.
+func (s *AdminService) BanUser(ctx context.Context, actor Actor, userID int) error {
+	if _, err := s.manageableUser(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if err := s.userRepo.SetBanned(ctx, userID, &now); err != nil {
+		return fmt.Errorf("failed to ban user: %w", err)
+	}
+
+	if err := s.authService.RevokeAllUserAccess(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.apiKeyService.RevokeAllForUser(ctx, userID)
+}
+
+func (s *AdminService) UnbanUser(ctx context.Context, actor Actor, userID int) error {
+	if _, err := s.manageableUser(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetBanned(ctx, userID, nil); err != nil {
+		return fmt.Errorf("failed to unban user: %w", err)
+	}
+
+	return nil
+}
+
+// ChangeRole sets the role of a user with a lower role than the actor, and
+// never to a role above the actor's own. Access tokens issued with the old
+// role are revoked; sessions stay and pick up the new role on refresh.
+func (s *AdminService) ChangeRole(ctx context.Context, actor Actor, userID int, role string) error {
+	if !rbac.ValidRole(role) {
+		return fmt.Errorf("%w %q", ErrInvalidRole, role)
+	}
+
+	if rbac.Outranks(role, actor.Role) {
+		return ErrInsufficientRank
+	}
+
+	if _, err := s.manageableUser(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateRole(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return s.revocationService.RevokeAllForUser(ctx, userID)
+}
+
+// Impersonate issues a short-lived access token that lets the actor see the
+// service as a user with a lower role, and records it in the audit table.
+// The token is read-only unless write access is asked for.
+func (s *AdminService) Impersonate(ctx context.Context, actor Actor, userID int, req dto.ImpersonateRequest, client dto.ClientInfo) (*dto.ImpersonationTokenResponse, error) {
+	target, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	actorUser, err := s.userRepo.GetUserById(ctx, actor.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	readOnly := req.ReadOnly == nil || *req.ReadOnly
+
+	generation, err := s.revocationService.TokenGeneration(ctx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, claims, err := s.jwtService.GenerateImpersonationToken(
+		target.ID, target.Username, target.Role, generation,
+		ActorClaims{Subject: strconv.Itoa(actorUser.ID), UserID: actorUser.ID, Username: actorUser.Username},
+		readOnly, s.impersonationTTL,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue impersonation token: %w", err)
+	}
+
+	session := &domain.ImpersonationSession{
+		ActorID:        &actorUser.ID,
+		ActorUsername:  actorUser.Username,
+		TargetUserID:   &target.ID,
+		TargetUsername: target.Username,
+		Reason:         req.Reason,
+		ReadOnly:       readOnly,
+		TokenID:        claims.ID,
+		IPAddress:      client.IPAddress,
+		UserAgent:      client.UserAgent,
+		ExpiresAt:      claims.ExpiresAt.Time.UTC(),
+	}
+
+	// Without the audit record the token must not be handed out.
+	if err := s.impersonationRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	log.Printf("impersonation started: session=%d actor=%d target=%d read_only=%t", session.ID, actorUser.ID, target.ID, readOnly)
+
+	return &dto.ImpersonationTokenResponse{
+		SessionID:   session.ID,
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.impersonationTTL.Seconds()),
+		ExpiresAt:   session.ExpiresAt,
+		ReadOnly:    readOnly,
+	}, nil
+}
+
+func (s *AdminService) ListImpersonations(ctx context.Context) ([]dto.ImpersonationSessionResponse, error) {
+	sessions, err := s.impersonationRepo.List(ctx, impersonationListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.ImpersonationSessionResponse, len(sessions))
+	for i := range sessions {
+		response[i] = dto.ToImpersonationSessionResponse(&sessions[i])
+	}
+
+	return response, nil
+}
+
+// EndImpersonation revokes the token of a running impersonation session
+// before it expires.
+func (s *AdminService) EndImpersonation(ctx context.Context, actor Actor, sessionID int) error {
+	session, err := s.impersonationRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if session == nil || session.TargetUserID == nil {
+		return ErrImpersonationEnded
+	}
+
+	ended, err := s.impersonationRepo.End(ctx, sessionID, actor.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !ended {
+		return ErrImpersonationEnded
+	}
+
+	claims := &Claims{
+		UserID: *session.TargetUserID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session.TokenID,
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+		},
+	}
+
+	return s.revocationService.RevokeAccessToken(ctx, claims)
+}
+
+// BootstrapAdmin makes the configured user an admin while there is no admin
+// yet, creating the account if needed. Once any admin exists it does nothing,
+// so the settings can stay in place safely.
+func (s *AdminService) BootstrapAdmin(ctx context.Context, cfg config.AdminBootstrapConfig) error {
+	if cfg.Username == "" {
+		return nil
+	}
+
+	exists, err := s.userRepo.ExistsWithRole(ctx, rbac.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	user, err := s.userRepo.FindByUsername(ctx, cfg.Username)
+	if err != nil {
+		return err
+	}
+
+	if user != nil {
+		if err := s.userRepo.UpdateRole(ctx, user.ID, rbac.RoleAdmin); err != nil {
+			return err
+		}
+
+		log.Printf("Bootstrap: user %q is now an admin", cfg.Username)
+		return nil
+	}
+
+	if cfg.Password == "" {
+		return ErrBootstrapNoPassword
+	}
+
+	if err := s.policy.Validate(cfg.Username, cfg.Password); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(cfg.Password)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	// The bootstrap account may use a reserved name such as "admin", so only
+	// the canonical form is applied, not the naming rules.
+	admin := &domain.User{
+		Username:     username.Canonical(cfg.Username),
+		PasswordHash: hash,
+		Role:         rbac.RoleAdmin,
+	}
+
+	if _, err := s.userRepo.Create(ctx, admin); err != nil {
+		return err
+	}
+
+	log.Printf("Bootstrap: created admin user %q", cfg.Username)
+	return nil
+}
+
+func (s *AdminService) manageableUser(ctx context.Context, actor Actor, userID int) (*domain.User, error) {
+	if actor.UserID == userID {
+		return nil, ErrCannotModifySelf
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !rbac.Outranks(actor.Role, user.Role) {
+		return nil, ErrInsufficientRank
+	}
+
+	return user, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"user-service/internal/authz"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+)
+
+// APIKeyPrefix starts every key, so leaked keys are easy to spot by secret
+// scanners and the middleware can tell keys from JWTs.
+const APIKeyPrefix = "usk_"
+
+const (
+	maxAPIKeysPerUser   = 20
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyScopes are the scopes an API key can be granted. Which actions need
+// which scope is declared in the authz policies.
+var APIKeyScopes = []string{authz.ScopeUsersRead, authz.ScopeUsersWrite}
+
+var (
+	ErrInvalidAPIKey  = errors.New("API key is invalid, expired or revoked")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrUnknownScope   = errors.New("unknown scope")
+	ErrTooManyAPIKeys = fmt.Errorf("a user can have at most %d active API keys", maxAPIKeysPerUser)
+)
+
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+// Create issues a new key. The returned key is not stored anywhere and
+// cannot be shown again.
+func (s *APIKeyService) Create(ctx context.Context, userID int, req dto.CreateAPIKeyRequest) (*dto.CreatedAPIKeyResponse, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !slices.Contains(APIKeyScopes, scope) {
+			return nil, fmt.Errorf("%w %q, allowed: %s", ErrUnknownScope, scope, strings.Join(APIKeyScopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	count, err := s.apiKeyRepo.CountActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if count >= maxAPIKeysPerUser {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	prefix, secret, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	rawKey := prefix + "_" + secret
+
+	key := &domain.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(rawKey),
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if req.ExpiresInDays > 0 {
+		expiresAt := key.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &dto.CreatedAPIKeyResponse{
+		APIKeyResponse: dto.ToAPIKeyResponse(key),
+		Key:            rawKey,
+	}, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context, userID int) ([]dto.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, dto.ToAPIKeyResponse(&keys[i]))
+	}
+
+	return response, nil
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, userID, keyID int) error {
+	revoked, err := s.apiKeyRepo.Revoke(ctx, userID, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (s *APIKeyService) RevokeAllForUser(ctx context.Context, userID int) error {
+	if err := s.apiKeyRepo.RevokeAllByUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate resolves a key presented by a client and records its use.
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey, ipAddress string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.FindByHash(ctx, hashToken(rawKey))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if key == nil || key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchLastUsed(ctx, key.ID, ipAddress, now.Add(-apiKeyTouchInterval)); err != nil {
+		return nil, fmt.Errorf("failed to record API key use: %w", err)
+	}
+
+	return key, nil
+}
+
+func newAPIKey() (string, string, error) {
+	id, err := randomHex(4)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	return APIKeyPrefix + id, base64.RawURLEncoding.EncodeToString(secret), nil
+}
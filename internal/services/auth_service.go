@@ -4,137 +4,517 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
 	"user-service/internal/domain"
 	"user-service/internal/dto"
+	"user-service/internal/password"
+	"user-service/internal/rbac"
 	"user-service/internal/repository"
+	"user-service/internal/username"
+)
 
-	"golang.org/x/crypto/bcrypt"
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used, the session was revoked")
+	ErrWrongPassword       = errors.New("current password is not correct")
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrAccountBanned       = errors.New("account is banned")
+	ErrUsernameTaken       = errors.New("username is already taken")
 )
 
 type AuthService struct {
-	jwtService *JWTService
-	userRepo   repository.UserRepository
+	jwtService        *JWTService
+	revocationService *RevocationService
+	throttler         *LoginThrottler
+	twoFactorService  *TwoFactorService
+	emailService      *EmailVerificationService
+	passwordPolicy    *password.Policy
+	hasher            password.Hasher
+	challengeTTL      time.Duration
+	userRepo          repository.UserRepository
+	sessionRepo       repository.SessionRepository
+	refreshTokenRepo  repository.RefreshTokenRepository
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtService *JWTService) *AuthService {
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	jwtService *JWTService,
+	revocationService *RevocationService,
+	throttler *LoginThrottler,
+	twoFactorService *TwoFactorService,
+	emailService *EmailVerificationService,
+	passwordPolicy *password.Policy,
+	hasher password.Hasher,
+	challengeTTL time.Duration,
+) *AuthService {
 	return &AuthService{
-		jwtService: jwtService,
-		userRepo:   userRepo,
+		jwtService:        jwtService,
+		revocationService: revocationService,
+		throttler:         throttler,
+		twoFactorService:  twoFactorService,
+		emailService:      emailService,
+		passwordPolicy:    passwordPolicy,
+		hasher:            hasher,
+		challengeTTL:      challengeTTL,
+		userRepo:          userRepo,
+		sessionRepo:       sessionRepo,
+		refreshTokenRepo:  refreshTokenRepo,
 	}
 }
 
+// Register creates an account under the canonical form of the requested
+// username. The lookups beforehand only give early answers; concurrent
+// registrations are settled by the unique indexes, whose violations come back
+// as ErrUsernameTaken or ErrEmailTaken.
 func (s *AuthService) Register(ctx context.Context, registerDto dto.RegisterRequest) (*domain.User, error) {
-	existingUser, err := s.userRepo.FindByUsername(ctx, registerDto.Username)
+	name, err := username.Normalize(registerDto.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordPolicy.Validate(name, registerDto.Password); err != nil {
+		return nil, err
+	}
+
+	existingUser, err := s.userRepo.FindByUsername(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
 	if existingUser != nil {
-		return nil, errors.New("user with that username already exists")
+		return nil, ErrUsernameTaken
+	}
+
+	email := strings.TrimSpace(registerDto.Email)
+	if email == "" && s.emailService.Required() {
+		return nil, ErrEmailRequired
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerDto.Password), bcrypt.DefaultCost)
+	if email != "" {
+		if err := s.emailService.CheckAvailable(ctx, email, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	hashedPassword, err := s.hasher.Hash(registerDto.Password)
 	if err != nil {
 		return nil, errors.New("failed to hash password")
 	}
 
 	user := &domain.User{
-		Username:     registerDto.Username,
-		PasswordHash: string(hashedPassword),
+		Username:     name,
+		PasswordHash: hashedPassword,
 		Balance:      0,
+		Role:         rbac.RoleUser,
+	}
+
+	if email != "" {
+		user.Email = &email
 	}
 
 	userID, err := s.userRepo.Create(ctx, user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, conflictError(err)
 	}
 
 	user.ID = userID
 
+	// The account exists at this point, a failed mail must not fail the
+	// registration. The user can ask for a new link later.
+	if err := s.emailService.SendVerification(ctx, user); err != nil {
+		log.Printf("failed to start email verification for user %d: %v", user.ID, err)
+	}
+
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, userDto dto.LoginRequest) (*dto.TokenResponse, error) {
+// Login checks the password. For accounts with two-factor authentication it
+// returns a short-lived challenge instead of tokens, see LoginTwoFactor.
+func (s *AuthService) Login(ctx context.Context, userDto dto.LoginRequest, client dto.ClientInfo) (*dto.LoginResult, error) {
+	if err := s.throttler.Check(ctx, userDto.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByUsername(ctx, userDto.Username)
 	if err != nil {
-		return &dto.TokenResponse{}, err
+		return nil, err
 	}
 
-	if user == nil {
-		return &dto.TokenResponse{}, errors.New("bad login data")
+	// Unknown usernames still pay for a hash comparison so that response
+	// times do not tell which accounts exist.
+	passwordHash := s.dummyPasswordHash()
+	if user != nil {
+		passwordHash = user.PasswordHash
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(userDto.Password)); err != nil {
-		return &dto.TokenResponse{}, errors.New("password is not correct")
+	if ok, _ := s.hasher.Verify(passwordHash, userDto.Password); !ok || user == nil {
+		if err := s.throttler.RecordFailure(ctx, userDto.Username, client.IPAddress); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Username)
+	s.rehashIfNeeded(ctx, user, userDto.Password)
+
+	client.DeviceName = userDto.DeviceName
+
+	result, err := s.CompleteLogin(ctx, user, client)
 	if err != nil {
-		return &dto.TokenResponse{}, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
 	}
 
-	refrseshToken, err := s.jwtService.GenerateRefreshToken(user.ID, user.Username)
+	if result.Tokens != nil {
+		if err := s.throttler.RecordSuccess(ctx, userDto.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// CompleteLogin continues a login once the first factor, a password or an
+// external identity provider, has been verified: it either starts a session
+// or asks for the second factor.
+func (s *AuthService) CompleteLogin(ctx context.Context, user *domain.User, client dto.ClientInfo) (*dto.LoginResult, error) {
+	if err := loginAllowed(user); err != nil {
+		return nil, err
+	}
+
+	twoFactorEnabled, err := s.twoFactorService.IsEnabled(ctx, user.ID)
 	if err != nil {
-		return &dto.TokenResponse{}, errors.New("failed to generate refresh token")
+		return nil, err
 	}
 
-	if err := s.userRepo.SaveRefreshToken(ctx, user.ID, refrseshToken); err != nil {
-		return &dto.TokenResponse{}, errors.New("failed to save refresh token")
+	if twoFactorEnabled {
+		challenge, err := s.jwtService.GenerateChallengeToken(user.ID, user.Username, s.challengeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate challenge token: %w", err)
+		}
+
+		return &dto.LoginResult{
+			Challenge: &dto.TwoFactorChallengeResponse{
+				TwoFactorRequired: true,
+				ChallengeToken:    challenge,
+				ExpiresIn:         int(s.challengeTTL.Seconds()),
+			},
+		}, nil
 	}
 
-	return &dto.TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refrseshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    15 * 60,
-	}, nil
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.LoginResult{Tokens: tokens}, nil
 }
 
-func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken string) (*dto.TokenResponse, error) {
+// CompletePasskeyLogin starts a session after a user-verified passkey
+// assertion. The passkey is something the user has, unlocked by something
+// they know or are, so it already stands for both factors and skips TOTP.
+func (s *AuthService) CompletePasskeyLogin(ctx context.Context, user *domain.User, client dto.ClientInfo) (*dto.TokenResponse, error) {
+	if err := loginAllowed(user); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, client)
+}
+
+// loginAllowed refuses banned users. Accounts pending deletion may sign in,
+// that is how their owners get back in to restore them.
+func loginAllowed(user *domain.User) error {
+	if user.BannedAt != nil {
+		return ErrAccountBanned
+	}
+
+	return nil
+}
+
+// LoginTwoFactor completes a login started by Login. Wrong codes count as
+// failed logins for the username, so guessing them is throttled the same way
+// as guessing passwords.
+func (s *AuthService) LoginTwoFactor(ctx context.Context, req dto.TwoFactorLoginRequest, client dto.ClientInfo) (*dto.TokenResponse, error) {
+	claims, err := s.jwtService.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.throttler.Check(ctx, claims.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorService.Verify(ctx, claims.UserID, req.Code); err != nil {
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			return nil, err
+		}
+		if err := s.throttler.RecordFailure(ctx, claims.Username, client.IPAddress); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if err := s.throttler.RecordSuccess(ctx, claims.Username); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.BannedAt != nil {
+		return nil, ErrAccountBanned
+	}
+
+	client.DeviceName = req.DeviceName
+
+	return s.startSession(ctx, user, client)
+}
+
+// RefreshAccessToken exchanges a refresh token for a new token pair. Every
+// refresh token is single-use: presenting one that was already rotated means
+// it has leaked, so the session it belongs to is revoked.
+func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken string, client dto.ClientInfo) (*dto.TokenResponse, error) {
 	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
 	if err != nil {
-		return nil, errors.New("refresh token invalid or expired")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	stored, err := s.refreshTokenRepo.FindByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if stored == nil || stored.RevokedAt != nil || stored.UserID != claims.UserID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.RotatedAt != nil {
+		return nil, s.revokeReusedSession(ctx, stored)
 	}
 
-	user, err := s.userRepo.FindByRefreshToken(ctx, refreshToken)
+	session, err := s.sessionRepo.GetByID(ctx, stored.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil || session.RevokedAt != nil || time.Now().UTC().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rotated, err := s.refreshTokenRepo.MarkRotated(ctx, stored.ID)
+	if err != nil {
+		return nil, errors.New("failed to rotate refresh token")
+	}
+
+	if !rotated {
+		return nil, s.revokeReusedSession(ctx, stored)
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, stored.UserID)
 	if err != nil {
 		return nil, errors.New("user is not found")
 	}
 
-	if user == nil {
-		return &dto.TokenResponse{}, errors.New("user is not found")
+	if user.BannedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	expiresAt := time.Now().UTC().Add(s.jwtService.RefreshTokenDuration())
+	if err := s.sessionRepo.Touch(ctx, session.ID, client.IPAddress, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return s.issueTokens(ctx, user, session.ID)
+}
+
+// Logout ends only the session the access token was issued for. The token
+// itself and the other access tokens of the session stop working right away.
+func (s *AuthService) Logout(ctx context.Context, claims *Claims) error {
+	if _, err := s.sessionRepo.Revoke(ctx, claims.UserID, claims.SessionID); err != nil {
+		return errors.New("logout error")
+	}
+
+	if err := s.revocationService.RevokeSession(ctx, claims.SessionID); err != nil {
+		return errors.New("logout error")
+	}
+
+	if err := s.revocationService.RevokeAccessToken(ctx, claims); err != nil {
+		return errors.New("logout error")
+	}
+
+	return nil
+}
+
+func (s *AuthService) LogoutAll(ctx context.Context, userID int) error {
+	if err := s.RevokeAllUserAccess(ctx, userID); err != nil {
+		return errors.New("logout error")
+	}
+
+	return nil
+}
+
+func (s *AuthService) ChangePassword(ctx context.Context, userID int, req dto.ChangePasswordRequest) error {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if ok, _ := s.hasher.Verify(user.PasswordHash, req.CurrentPassword); !ok {
+		return ErrWrongPassword
+	}
+
+	if err := s.passwordPolicy.Validate(user.Username, req.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
 	}
 
-	if user.ID != claims.UserID {
-		return nil, errors.New("token does not belong to the user")
+	return s.RevokeAllUserAccess(ctx, userID)
+}
+
+// RevokeAllUserAccess ends every session of the user and invalidates all
+// access tokens issued to them so far.
+func (s *AuthService) RevokeAllUserAccess(ctx context.Context, userID int) error {
+	if err := s.sessionRepo.RevokeAllByUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := s.revocationService.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (s *AuthService) revokeReusedSession(ctx context.Context, token *domain.RefreshToken) error {
+	if _, err := s.sessionRepo.Revoke(ctx, token.UserID, token.SessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if err := s.revocationService.RevokeSession(ctx, token.SessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
 	}
 
-	newAccessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Username)
+	return ErrRefreshTokenReused
+}
+
+func (s *AuthService) startSession(ctx context.Context, user *domain.User, client dto.ClientInfo) (*dto.TokenResponse, error) {
+	now := time.Now().UTC()
+
+	session := &domain.Session{
+		UserID:     user.ID,
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.jwtService.RefreshTokenDuration()),
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, errors.New("failed to create session")
+	}
+
+	return s.issueTokens(ctx, user, session.ID)
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, user *domain.User, sessionID int) (*dto.TokenResponse, error) {
+	generation, err := s.revocationService.TokenGeneration(ctx, user.ID)
 	if err != nil {
-		return nil, errors.New("failed to generate access token")
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user.ID, user.Username)
+	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Username, user.Role, sessionID, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	refreshToken, err := s.jwtService.GenerateRefreshToken(user.ID, user.Username)
 	if err != nil {
 		return nil, errors.New("failed to generate refresh token")
 	}
 
-	if err := s.userRepo.SaveRefreshToken(ctx, user.ID, newRefreshToken); err != nil {
-		return nil, errors.New("failed to refresh ref-token")
+	stored := &domain.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().UTC().Add(s.jwtService.RefreshTokenDuration()),
+	}
+
+	if err := s.refreshTokenRepo.Create(ctx, stored); err != nil {
+		return nil, errors.New("failed to save refresh token")
 	}
 
 	return &dto.TokenResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
 		ExpiresIn:    15 * 60,
 	}, nil
 }
 
-func (s *AuthService) Logout(ctx context.Context, userID int) error {
-	if err := s.userRepo.RevokeRefreshToken(ctx, userID); err != nil {
-		return errors.New("logout error")
+// rehashIfNeeded upgrades a hash made by an older algorithm or with weaker
+// parameters while the plain password is at hand. A failure only delays the
+// upgrade to the next login, so it does not fail the login itself.
+func (s *AuthService) rehashIfNeeded(ctx context.Context, user *domain.User, plainPassword string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
 	}
 
-	return nil
+	hash, err := s.hasher.Hash(plainPassword)
+	if err != nil {
+		log.Printf("failed to rehash password of user %d: %v", user.ID, err)
+		return
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hash); err != nil {
+		log.Printf("failed to save rehashed password of user %d: %v", user.ID, err)
+		return
+	}
+
+	user.PasswordHash = hash
+}
+
+func (s *AuthService) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.hasher.Hash("dummy-password-for-timing")
+		if err == nil {
+			s.dummyHash = hash
+		}
+	})
+
+	return s.dummyHash
+}
+
+// conflictError maps a unique violation on user creation to the matching
+// service error.
+func conflictError(err error) error {
+	var conflict *repository.ConflictError
+	if !errors.As(err, &conflict) {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	switch conflict.Field {
+	case "username":
+		return ErrUsernameTaken
+	case "email":
+		return ErrEmailTaken
+	default:
+		return fmt.Errorf("failed to create user: %w", err)
+	}
 }
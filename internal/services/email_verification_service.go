@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/mailer"
+	"user-service/internal/repository"
+)
+
+// Restrictions that EMAIL_UNVERIFIED_RESTRICTIONS can put on users without a
+// verified email.
+const (
+	RestrictCompleteTasks = "complete_tasks"
+	RestrictLeaderboard   = "leaderboard"
+	RestrictSetReferrer   = "referrer"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("verification token is invalid or expired")
+	ErrEmailRequired            = errors.New("email is required")
+	ErrEmailTaken               = errors.New("email is already in use")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+)
+
+type EmailVerificationService struct {
+	userRepo         repository.UserRepository
+	verificationRepo repository.EmailVerificationRepository
+	mailer           mailer.Mailer
+	required         bool
+	verificationURL  string
+	tokenTTL         time.Duration
+	restrictions     map[string]bool
+}
+
+func NewEmailVerificationService(
+	cfg config.EmailConfig,
+	userRepo repository.UserRepository,
+	verificationRepo repository.EmailVerificationRepository,
+	mailer mailer.Mailer,
+) (*EmailVerificationService, error) {
+	restrictions := make(map[string]bool, len(cfg.UnverifiedRestrictions))
+	for _, restriction := range cfg.UnverifiedRestrictions {
+		switch restriction {
+		case RestrictCompleteTasks, RestrictLeaderboard, RestrictSetReferrer:
+			restrictions[restriction] = true
+		default:
+			return nil, fmt.Errorf("unknown unverified email restriction %q", restriction)
+		}
+	}
+
+	return &EmailVerificationService{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
+		required:         cfg.Required,
+		verificationURL:  cfg.VerificationURL,
+		tokenTTL:         cfg.VerificationTokenTTL,
+		restrictions:     restrictions,
+	}, nil
+}
+
+// Required reports whether new accounts must have an email address.
+func (s *EmailVerificationService) Required() bool {
+	return s.required
+}
+
+// Restricts reports whether the restriction applies to unverified users.
+func (s *EmailVerificationService) Restricts(restriction string) bool {
+	return s.restrictions[restriction]
+}
+
+// Check returns ErrEmailNotVerified when the restriction is enabled and the
+// user has not verified an address.
+func (s *EmailVerificationService) Check(user *domain.User, restriction string) error {
+	if s.restrictions[restriction] && !user.EmailVerified() {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// CheckAvailable returns ErrEmailTaken when the address, compared case
+// insensitively, belongs to a user other than userID.
+func (s *EmailVerificationService) CheckAvailable(ctx context.Context, email string, userID int) error {
+	owner, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if owner != nil && owner.ID != userID {
+		return ErrEmailTaken
+	}
+
+	return nil
+}
+
+// ChangeEmail sets a new address for the user and mails a verification link
+// to it. Submitting the current unverified address again resends the link.
+func (s *EmailVerificationService) ChangeEmail(ctx context.Context, userID int, email string) error {
+	email = strings.TrimSpace(email)
+
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Email != nil && strings.EqualFold(*user.Email, email) {
+		if user.EmailVerified() {
+			return nil
+		}
+		return s.SendVerification(ctx, user)
+	}
+
+	if err := s.CheckAvailable(ctx, email, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateEmail(ctx, userID, &email); err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+
+	user.Email = &email
+	user.EmailVerifiedAt = nil
+
+	return s.SendVerification(ctx, user)
+}
+
+// SendVerification replaces any pending link of the user with a new one and
+// mails it in the background.
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *domain.User) error {
+	if user.Email == nil {
+		return nil
+	}
+
+	if err := s.verificationRepo.InvalidateByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to invalidate previous verification tokens: %w", err)
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+
+	verificationToken := &domain.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     *user.Email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(s.tokenTTL),
+	}
+
+	if err := s.verificationRepo.Create(ctx, verificationToken); err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      *user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"This address was added to the account %q.\n\nOpen the link below to confirm it, it is valid for %s:\n%s\n\nIf it was not you, ignore this message.",
+			user.Username, s.tokenTTL, s.verificationLink(token),
+		),
+	}
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(sendCtx, msg); err != nil {
+			log.Printf("failed to send verification mail to user %d: %v", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// Verify consumes the token and marks its address as verified. A token for an
+// address the user has replaced since it was mailed is rejected.
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) error {
+	verificationToken, err := s.verificationRepo.Consume(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	if verificationToken == nil {
+		return ErrInvalidVerificationToken
+	}
+
+	verified, err := s.userRepo.MarkEmailVerified(ctx, verificationToken.UserID, verificationToken.Email)
+	if err != nil {
+		return fmt.Errorf("failed to mark email as verified: %w", err)
+	}
+
+	if !verified {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+func (s *EmailVerificationService) verificationLink(token string) string {
+	u, err := url.Parse(s.verificationURL)
+	if err != nil {
+		return s.verificationURL + "?token=" + url.QueryEscape(token)
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
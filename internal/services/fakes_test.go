@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+)
+
+// The fakes embed the repository interfaces and implement only what the
+// tests reach; anything else panics on the nil embedded value.
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[int]*domain.User
+}
+
+func newFakeUserRepo(users ...*domain.User) *fakeUserRepo {
+	repo := &fakeUserRepo{users: make(map[int]*domain.User)}
+	for _, user := range users {
+		repo.add(user)
+	}
+	return repo
+}
+
+func (r *fakeUserRepo) add(user *domain.User) {
+	if user.ID == 0 {
+		user.ID = len(r.users) + 1
+	}
+	r.users[user.ID] = user
+}
+
+func (r *fakeUserRepo) GetUserById(ctx context.Context, id int) (*domain.User, error) {
+	return r.users[id], nil
+}
+
+func (r *fakeUserRepo) FindByUsername(ctx context.Context, name string) (*domain.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, name) {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Email != nil && strings.EqualFold(*user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepo) ScheduleDeletion(ctx context.Context, userID int, deletedAt, purgeAfter time.Time) (bool, error) {
+	user := r.users[userID]
+	if user == nil || user.DeletedAt != nil {
+		return false, nil
+	}
+	user.DeletedAt, user.PurgeAfter = &deletedAt, &purgeAfter
+	return true, nil
+}
+
+func (r *fakeUserRepo) CancelDeletion(ctx context.Context, userID int, now time.Time) (bool, error) {
+	user := r.users[userID]
+	if user == nil || user.DeletedAt == nil || !user.PurgeAfter.After(now) {
+		return false, nil
+	}
+	user.DeletedAt, user.PurgeAfter = nil, nil
+	return true, nil
+}
+
+type fakeSessionRepo struct {
+	repository.SessionRepository
+	sessions []domain.Session
+}
+
+func (r *fakeSessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	session.ID = len(r.sessions) + 1
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+func (r *fakeSessionRepo) GetByID(ctx context.Context, id int) (*domain.Session, error) {
+	for i := range r.sessions {
+		if r.sessions[i].ID == id {
+			return &r.sessions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepo) RevokeAllByUser(ctx context.Context, userID int) error {
+	now := time.Now().UTC()
+	for i := range r.sessions {
+		if r.sessions[i].UserID == userID && r.sessions[i].RevokedAt == nil {
+			r.sessions[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeRefreshTokenRepo struct {
+	repository.RefreshTokenRepository
+	tokens []domain.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepo) Create(ctx context.Context, token *domain.RefreshToken) error {
+	token.ID = len(r.tokens) + 1
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+type fakeTwoFactorRepo struct {
+	repository.TwoFactorRepository
+}
+
+func (r *fakeTwoFactorRepo) GetTOTP(ctx context.Context, userID int) (*domain.UserTOTP, error) {
+	return nil, nil
+}
+
+// newTestAuthService wires an AuthService that can complete logins for the
+// users in userRepo. Password logins are not set up.
+func newTestAuthService(t *testing.T, userRepo repository.UserRepository) (*AuthService, *fakeSessionRepo) {
+	t.Helper()
+
+	keys, err := NewEphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtService := NewJWTService(config.JWTConfig{
+		Issuer:               "user-service-test",
+		Audience:             "user-service-test",
+		AccessTokenDuration:  15 * time.Minute,
+		RefreshTokenDuration: time.Hour,
+	}, keys)
+
+	sessions := &fakeSessionRepo{}
+	authService := NewAuthService(
+		userRepo,
+		sessions,
+		&fakeRefreshTokenRepo{},
+		jwtService,
+		NewRevocationService(newMemoryRevocationStore()),
+		nil,
+		NewTwoFactorService(&fakeTwoFactorRepo{}, nil, "user-service-test"),
+		nil,
+		nil,
+		nil,
+		time.Minute,
+	)
+
+	return authService, sessions
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/password"
+	"user-service/internal/rbac"
+	"user-service/internal/repository"
+	"user-service/internal/username"
+)
+
+var (
+	ErrInvalidGuestCredential = errors.New("device secret is invalid")
+	ErrNotGuest               = errors.New("account is not a guest account")
+	ErrGuestNotAllowed        = errors.New("guest accounts cannot do this, upgrade to a full account first")
+	ErrGuestRateLimited       = errors.New("too many guest accounts created, try again later")
+)
+
+const (
+	guestSecretPrefix = "gst_"
+	// "#" is not allowed in usernames, so placeholders never collide with
+	// real names.
+	guestUsernamePrefix = "guest#"
+)
+
+// GuestService manages accounts that start without a username and password.
+// A guest signs in with a secret bound to its device and can later be upgraded
+// to a full account, keeping its id, balance and completed tasks.
+type GuestService struct {
+	guestRepo      repository.GuestRepository
+	userRepo       repository.UserRepository
+	authService    *AuthService
+	emailService   *EmailVerificationService
+	passwordPolicy *password.Policy
+	hasher         password.Hasher
+	retention      time.Duration
+	maxPerIP       int
+	rateWindow     time.Duration
+}
+
+func NewGuestService(
+	cfg config.GuestConfig,
+	guestRepo repository.GuestRepository,
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	emailService *EmailVerificationService,
+	passwordPolicy *password.Policy,
+	hasher password.Hasher,
+) *GuestService {
+	return &GuestService{
+		guestRepo:      guestRepo,
+		userRepo:       userRepo,
+		authService:    authService,
+		emailService:   emailService,
+		passwordPolicy: passwordPolicy,
+		hasher:         hasher,
+		retention:      cfg.Retention,
+		maxPerIP:       cfg.MaxPerIP,
+		rateWindow:     cfg.RateWindow,
+	}
+}
+
+// CreateGuest creates a guest account and signs it in. The device secret in
+// the response is shown only once. Guests need no credentials, so the number
+// created from one IP is limited.
+func (s *GuestService) CreateGuest(ctx context.Context, req dto.CreateGuestRequest, client dto.ClientInfo) (*dto.GuestResponse, error) {
+	created, err := s.guestRepo.CountSinceByIP(ctx, client.IPAddress, time.Now().UTC().Add(-s.rateWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	if created >= s.maxPerIP {
+		return nil, ErrGuestRateLimited
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	secret = guestSecretPrefix + secret
+
+	suffix, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		Username: guestUsernamePrefix + suffix,
+		Role:     rbac.RoleUser,
+		IsGuest:  true,
+	}
+
+	now := time.Now().UTC()
+	credential := &domain.GuestCredential{
+		SecretHash: hashToken(secret),
+		DeviceName: req.DeviceName,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+
+	if err := s.guestRepo.CreateGuest(ctx, user, credential); err != nil {
+		return nil, err
+	}
+
+	client.DeviceName = req.DeviceName
+
+	result, err := s.authService.CompleteLogin(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Tokens == nil {
+		return nil, errors.New("failed to sign in the guest")
+	}
+
+	return &dto.GuestResponse{
+		TokenResponse: *result.Tokens,
+		UserID:        user.ID,
+		DeviceSecret:  secret,
+	}, nil
+}
+
+// Login signs a guest in again with its device secret.
+func (s *GuestService) Login(ctx context.Context, req dto.GuestLoginRequest, client dto.ClientInfo) (*dto.LoginResult, error) {
+	credential, err := s.guestRepo.FindCredential(ctx, hashToken(req.DeviceSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	if credential == nil {
+		return nil, ErrInvalidGuestCredential
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, credential.UserID)
+	if err != nil || !user.IsGuest {
+		return nil, ErrInvalidGuestCredential
+	}
+
+	if err := s.guestRepo.TouchCredential(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to update guest credential: %w", err)
+	}
+
+	client.DeviceName = req.DeviceName
+
+	return s.authService.CompleteLogin(ctx, user, client)
+}
+
+// Upgrade gives the guest a username, a password and optionally an email
+// under the same rules as a registration. The device secret stops working;
+// the session the request came with is ended and a new one is started.
+func (s *GuestService) Upgrade(ctx context.Context, claims *Claims, req dto.UpgradeGuestRequest, client dto.ClientInfo) (*dto.LoginResult, error) {
+	user, err := s.userRepo.GetUserById(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.IsGuest {
+		return nil, ErrNotGuest
+	}
+
+	name, err := username.Normalize(req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordPolicy.Validate(name, req.Password); err != nil {
+		return nil, err
+	}
+
+	existingUser, err := s.userRepo.FindByUsername(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if existingUser != nil {
+		return nil, ErrUsernameTaken
+	}
+
+	var email *string
+	if trimmed := strings.TrimSpace(req.Email); trimmed != "" {
+		if err := s.emailService.CheckAvailable(ctx, trimmed, user.ID); err != nil {
+			return nil, err
+		}
+		email = &trimmed
+	} else if s.emailService.Required() {
+		return nil, ErrEmailRequired
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+
+	upgraded, err := s.guestRepo.Upgrade(ctx, user.ID, name, hash, email)
+	if err != nil {
+		return nil, conflictError(err)
+	}
+
+	if !upgraded {
+		return nil, ErrNotGuest
+	}
+
+	user.Username = name
+	user.PasswordHash = hash
+	user.Email = email
+	user.EmailVerifiedAt = nil
+	user.IsGuest = false
+
+	if err := s.emailService.SendVerification(ctx, user); err != nil {
+		log.Printf("failed to start email verification for user %d: %v", user.ID, err)
+	}
+
+	// The current access token still names the guest placeholder.
+	if err := s.authService.Logout(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	client.DeviceName = req.DeviceName
+
+	return s.authService.CompleteLogin(ctx, user, client)
+}
+
+// DeleteInactive removes guest accounts unused for longer than the
+// retention period.
+func (s *GuestService) DeleteInactive(ctx context.Context) (int64, error) {
+	return s.guestRepo.DeleteInactive(ctx, time.Now().UTC().Add(-s.retention))
+}
+
+// RunCleanup deletes inactive guests every interval until ctx is done.
+func (s *GuestService) RunCleanup(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, interval, "cleanup of inactive guests", s.DeleteInactive)
+}
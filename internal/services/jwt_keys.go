@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"user-service/internal/config"
+	"user-service/internal/dto"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet holds the key used to sign new tokens and every key that tokens may
+// still be verified with while a rotation is in progress.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+	order  []string
+}
+
+func LoadKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*signingKey)}
+
+	for _, keyCfg := range cfg.Keys {
+		data, err := os.ReadFile(keyCfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key %q: %w", keyCfg.ID, err)
+		}
+
+		key, err := parsePEMKey(keyCfg.ID, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT key %q: %w", keyCfg.ID, err)
+		}
+
+		if err := set.add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	active, ok := set.keys[cfg.SigningKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", cfg.SigningKeyID)
+	}
+
+	if active.private == nil {
+		return nil, fmt.Errorf("signing key %q must be a private key", cfg.SigningKeyID)
+	}
+
+	set.active = active
+
+	return set, nil
+}
+
+// NewEphemeralKeySet generates an in-memory Ed25519 key. Tokens signed with it
+// do not survive a restart, so it is only meant for local development.
+func NewEphemeralKeySet() (*KeySet, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT key: %w", err)
+	}
+
+	kid, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		id:      kid,
+		method:  jwt.SigningMethodEdDSA,
+		private: private,
+		public:  public,
+	}
+
+	set := &KeySet{keys: make(map[string]*signingKey)}
+	if err := set.add(key); err != nil {
+		return nil, err
+	}
+	set.active = key
+
+	return set, nil
+}
+
+func (k *KeySet) add(key *signingKey) error {
+	if _, exists := k.keys[key.id]; exists {
+		return fmt.Errorf("duplicate JWT key id %q", key.id)
+	}
+
+	k.keys[key.id] = key
+	k.order = append(k.order, key.id)
+
+	return nil
+}
+
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+
+	signed, err := token.SignedString(k.active.private)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signed, nil
+}
+
+// keyFunc resolves the verification key by the kid header and refuses tokens
+// whose alg does not match the algorithm that key was issued for.
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.public, nil
+}
+
+func (k *KeySet) validMethods() []string {
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+func (k *KeySet) JWKS() dto.JWKSet {
+	set := dto.JWKSet{Keys: make([]dto.JWK, 0, len(k.order))}
+
+	for _, kid := range k.order {
+		key := k.keys[kid]
+
+		jwk := dto.JWK{
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func parsePEMKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{id: kid}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 are allowed", parsed)
+	}
+
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+	}
+
+	return key, nil
+}
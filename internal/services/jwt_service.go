@@ -4,63 +4,104 @@ import (
 	"errors"
 	"fmt"
 	"time"
+	"user-service/internal/config"
+	"user-service/internal/dto"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	TokenUseAccess             = "access"
+	TokenUseRefresh            = "refresh"
+	TokenUseTwoFactorChallenge = "2fa_challenge"
+	TokenUseMagicLink          = "magic_link"
+)
+
+var errWrongTokenUse = errors.New("token is not meant for this purpose")
+
+// Claims are the claims of every token the service issues. Gen is the token
+// generation of the user at issue time, see RevocationService.RevokeAllForUser.
 type Claims struct {
+	UserID    int          `json:"user_id"`
+	Username  string       `json:"username"`
+	Role      string       `json:"role,omitempty"`
+	SessionID int          `json:"sid,omitempty"`
+	TokenUse  string       `json:"token_use"`
+	Actor     *ActorClaims `json:"act,omitempty"`
+	ReadOnly  bool         `json:"read_only,omitempty"`
+	Gen       int          `json:"gen,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ActorClaims identifies who is really behind an impersonation token, in the
+// shape of the RFC 8693 "act" claim.
+type ActorClaims struct {
+	Subject  string `json:"sub"`
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
-	jwt.RegisteredClaims
+}
+
+// Impersonated reports whether the token was issued to a staff member acting
+// as the user.
+func (c *Claims) Impersonated() bool {
+	return c.Actor != nil
 }
 
 type JWTService struct {
-	secretKey            []byte
+	keys                 *KeySet
+	issuer               string
+	audience             string
+	leeway               time.Duration
 	accessTokenDuration  time.Duration
 	refreshTokenDuration time.Duration
 }
 
-func NewJWTService(secretKey string, accessDuration, refreshDuration int) *JWTService {
+func NewJWTService(cfg config.JWTConfig, keys *KeySet) *JWTService {
 	return &JWTService{
-		secretKey:            []byte(secretKey),
-		accessTokenDuration:  time.Duration(accessDuration),
-		refreshTokenDuration: time.Duration(refreshDuration),
+		keys:                 keys,
+		issuer:               cfg.Issuer,
+		audience:             cfg.Audience,
+		leeway:               cfg.Leeway,
+		accessTokenDuration:  cfg.AccessTokenDuration,
+		refreshTokenDuration: cfg.RefreshTokenDuration,
 	}
 }
 
-func (j *JWTService) GenerateAccessToken(userID int, username string) (string, error) {
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+func (j *JWTService) GenerateAccessToken(userID int, username, role string, sessionID, generation int) (string, error) {
+	claims, err := j.newClaims(userID, username, TokenUseAccess, j.accessTokenDuration)
+	if err != nil {
+		return "", err
 	}
+	claims.Role = role
+	claims.SessionID = sessionID
+	claims.Gen = generation
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return j.keys.sign(claims)
+}
 
-	signedToken, err := token.SignedString(j.secretKey)
+// GenerateImpersonationToken issues an access token for the user that names
+// the actor in the act claim. It belongs to no session, so it cannot be
+// refreshed and simply expires after ttl.
+func (j *JWTService) GenerateImpersonationToken(userID int, username, role string, generation int, actor ActorClaims, readOnly bool, ttl time.Duration) (string, *Claims, error) {
+	claims, err := j.newClaims(userID, username, TokenUseAccess, ttl)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", nil, err
 	}
+	claims.Role = role
+	claims.Gen = generation
+	claims.Actor = &actor
+	claims.ReadOnly = readOnly
 
-	return signedToken, nil
+	token, err := j.keys.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, claims, nil
 }
 
 func (j *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&Claims{},
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-
-			}
-			return j.secretKey, nil
-		},
-	)
-
+	claims, err := j.parse(tokenString, TokenUseAccess)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
 			return nil, fmt.Errorf("the token has expired")
@@ -68,51 +109,117 @@ func (j *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("errof while validating access token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	return claims, nil
+}
+
+func (j *JWTService) GenerateRefreshToken(userID int, username string) (string, error) {
+	claims, err := j.newClaims(userID, username, TokenUseRefresh, j.refreshTokenDuration)
+	if err != nil {
+		return "", err
 	}
 
-	return nil, errors.New("invalid token")
+	return j.keys.sign(claims)
 }
 
-func (j *JWTService) GenerateRefreshToken(userID int, username string) (string, error) {
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.refreshTokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+func (j *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return j.parse(tokenString, TokenUseRefresh)
+}
+
+// GenerateChallengeToken proves that the password step of a login succeeded.
+// It is exchanged for real tokens together with a second factor code.
+func (j *JWTService) GenerateChallengeToken(userID int, username string, ttl time.Duration) (string, error) {
+	claims, err := j.newClaims(userID, username, TokenUseTwoFactorChallenge, ttl)
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(j.secretKey)
+	return j.keys.sign(claims)
+}
+
+func (j *JWTService) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return j.parse(tokenString, TokenUseTwoFactorChallenge)
+}
+
+// GenerateMagicLinkToken issues the token mailed in a sign-in link. Its jti
+// is recorded so that the link can be used only once.
+func (j *JWTService) GenerateMagicLinkToken(userID int, username string, ttl time.Duration) (string, *Claims, error) {
+	claims, err := j.newClaims(userID, username, TokenUseMagicLink, ttl)
 	if err != nil {
-		return "", nil
+		return "", nil, err
 	}
 
-	return tokenString, nil
+	token, err := j.keys.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, claims, nil
 }
 
-func (j *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+func (j *JWTService) ValidateMagicLinkToken(tokenString string) (*Claims, error) {
+	return j.parse(tokenString, TokenUseMagicLink)
+}
+
+func (j *JWTService) RefreshTokenDuration() time.Duration {
+	return j.refreshTokenDuration
+}
+
+// JWKS returns the public part of every configured key so other services
+// can verify our tokens without holding anything that can mint them.
+func (j *JWTService) JWKS() dto.JWKSet {
+	return j.keys.JWKS()
+}
+
+func (j *JWTService) newClaims(userID int, username, tokenUse string, duration time.Duration) (*Claims, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return &Claims{
+		UserID:   userID,
+		Username: username,
+		TokenUse: tokenUse,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    j.issuer,
+			Audience:  jwt.ClaimStrings{j.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}, nil
+}
+
+// parse verifies the signature and the registered claims, and accepts the
+// token only if it was issued for the expected use, so a refresh token can
+// never pass as an access token or the other way round.
+func (j *JWTService) parse(tokenString, tokenUse string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("the token has expired")
-			}
-			return j.secretKey, nil
-		},
+		j.keys.keyFunc,
+		jwt.WithValidMethods(j.keys.validMethods()),
+		jwt.WithIssuer(j.issuer),
+		jwt.WithAudience(j.audience),
+		jwt.WithLeeway(j.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if claims.TokenUse != tokenUse {
+		return nil, errWrongTokenUse
 	}
 
-	return nil, errors.New("invalid refresh token")
+	return claims, nil
 }
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+	"user-service/internal/username"
+)
+
+// LockoutError is returned while a username or client IP is not allowed to
+// attempt a login.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+type LoginThrottler struct {
+	attemptRepo repository.LoginAttemptRepository
+	cfg         config.LoginProtectionConfig
+}
+
+func NewLoginThrottler(cfg config.LoginProtectionConfig, attemptRepo repository.LoginAttemptRepository) *LoginThrottler {
+	return &LoginThrottler{
+		attemptRepo: attemptRepo,
+		cfg:         cfg,
+	}
+}
+
+// Check fails with a *LockoutError if either the username or the IP is still
+// backing off or locked.
+func (t *LoginThrottler) Check(ctx context.Context, username, ip string) error {
+	for _, key := range t.keys(username, ip) {
+		attempt, err := t.attemptRepo.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if attempt == nil || attempt.LockedUntil == nil {
+			continue
+		}
+
+		if wait := time.Until(*attempt.LockedUntil); wait > 0 {
+			return &LockoutError{RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+func (t *LoginThrottler) RecordFailure(ctx context.Context, username, ip string) error {
+	windowStart := time.Now().UTC().Add(-t.cfg.AttemptWindow)
+
+	userKey := usernameAttemptKey(username)
+	failures, err := t.attemptRepo.RecordFailure(ctx, userKey, windowStart)
+	if err != nil {
+		return err
+	}
+
+	if delay := t.usernameDelay(failures); delay > 0 {
+		if err := t.lock(ctx, userKey, failures, delay); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	ipKey := ipAttemptKey(ip)
+	failures, err = t.attemptRepo.RecordFailure(ctx, ipKey, windowStart)
+	if err != nil {
+		return err
+	}
+
+	if failures >= t.cfg.IPMaxAttempts {
+		return t.lock(ctx, ipKey, failures, t.cfg.LockoutDuration)
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the username counter only. The IP counter is kept so
+// that logging into an own account does not reset a credential stuffing run.
+func (t *LoginThrottler) RecordSuccess(ctx context.Context, username string) error {
+	return t.attemptRepo.Reset(ctx, usernameAttemptKey(username))
+}
+
+func (t *LoginThrottler) ListLockouts(ctx context.Context) ([]dto.LockoutResponse, error) {
+	attempts, err := t.attemptRepo.ListLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.LockoutResponse, len(attempts))
+	for i, attempt := range attempts {
+		response[i] = dto.ToLockoutResponse(&attempt)
+	}
+
+	return response, nil
+}
+
+func (t *LoginThrottler) Unlock(ctx context.Context, key string) error {
+	return t.attemptRepo.Reset(ctx, key)
+}
+
+func (t *LoginThrottler) usernameDelay(failures int) time.Duration {
+	if failures >= t.cfg.MaxAttempts {
+		return t.cfg.LockoutDuration
+	}
+
+	if failures <= t.cfg.FreeAttempts {
+		return 0
+	}
+
+	delay := t.cfg.BackoffBase << (failures - t.cfg.FreeAttempts - 1)
+	if delay <= 0 || delay > t.cfg.LockoutDuration {
+		return t.cfg.LockoutDuration
+	}
+
+	return delay
+}
+
+func (t *LoginThrottler) lock(ctx context.Context, key string, failures int, delay time.Duration) error {
+	until := time.Now().UTC().Add(delay)
+
+	if delay >= t.cfg.LockoutDuration {
+		log.Printf("login locked: key=%s failures=%d until=%s", key, failures, until.Format(time.RFC3339))
+	}
+
+	return t.attemptRepo.Lock(ctx, key, until)
+}
+
+func (t *LoginThrottler) keys(username, ip string) []string {
+	keys := []string{usernameAttemptKey(username)}
+	if ip != "" {
+		keys = append(keys, ipAttemptKey(ip))
+	}
+	return keys
+}
+
+func usernameAttemptKey(name string) string {
+	return "user:" + username.Canonical(name)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/mailer"
+	"user-service/internal/repository"
+)
+
+var (
+	ErrInvalidMagicLink     = errors.New("sign-in link is invalid, expired or was opened on another device")
+	ErrMagicLinkRateLimited = errors.New("too many sign-in links requested, try again later")
+)
+
+// MagicLinkService signs users in with single-use links sent through the
+// mailer. A link only works together with the binding secret handed to the
+// device that asked for it, so forwarding the mail does not pass the login on.
+type MagicLinkService struct {
+	userRepo    repository.UserRepository
+	linkRepo    repository.MagicLinkRepository
+	jwtService  *JWTService
+	authService *AuthService
+	mailer      mailer.Mailer
+	linkURL     string
+	tokenTTL    time.Duration
+	maxPerEmail int
+	maxPerIP    int
+	rateWindow  time.Duration
+}
+
+func NewMagicLinkService(
+	cfg config.MagicLinkConfig,
+	userRepo repository.UserRepository,
+	linkRepo repository.MagicLinkRepository,
+	jwtService *JWTService,
+	authService *AuthService,
+	mailer mailer.Mailer,
+) *MagicLinkService {
+	return &MagicLinkService{
+		userRepo:    userRepo,
+		linkRepo:    linkRepo,
+		jwtService:  jwtService,
+		authService: authService,
+		mailer:      mailer,
+		linkURL:     cfg.URL,
+		tokenTTL:    cfg.TokenTTL,
+		maxPerEmail: cfg.MaxPerEmail,
+		maxPerIP:    cfg.MaxPerIP,
+		rateWindow:  cfg.RateWindow,
+	}
+}
+
+// TokenTTL is how long a requested link stays valid.
+func (s *MagicLinkService) TokenTTL() time.Duration {
+	return s.tokenTTL
+}
+
+// RequestLink mails a sign-in link to the owner of the address and returns
+// the binding secret the requesting device needs to use it. The answer is the
+// same whether or not the address is known, and the mail goes out in the
+// background so the response time does not tell either.
+func (s *MagicLinkService) RequestLink(ctx context.Context, req dto.MagicLinkRequest, client dto.ClientInfo) (string, error) {
+	// Spelling variants of one address share the per-email limit.
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	since := time.Now().UTC().Add(-s.rateWindow)
+
+	byEmail, err := s.linkRepo.CountSinceByEmail(ctx, email, since)
+	if err != nil {
+		return "", err
+	}
+
+	byIP, err := s.linkRepo.CountSinceByIP(ctx, client.IPAddress, since)
+	if err != nil {
+		return "", err
+	}
+
+	if byEmail >= s.maxPerEmail || byIP >= s.maxPerIP {
+		return "", ErrMagicLinkRateLimited
+	}
+
+	binding, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return "", err
+	}
+
+	link := &domain.MagicLink{
+		Email:       email,
+		BindingHash: hashToken(binding),
+		IPAddress:   client.IPAddress,
+		ExpiresAt:   time.Now().UTC().Add(s.tokenTTL),
+	}
+
+	var token string
+	// Anyone can put any address on an account, so only one the owner has
+	// verified may sign in to it.
+	if user != nil && user.EmailVerified() {
+		var claims *Claims
+		token, claims, err = s.jwtService.GenerateMagicLinkToken(user.ID, user.Username, s.tokenTTL)
+		if err != nil {
+			return "", fmt.Errorf("failed to issue sign-in token: %w", err)
+		}
+
+		link.UserID = &user.ID
+		link.TokenID = claims.ID
+	} else {
+		// Recorded only so that the request counts towards the limits.
+		if link.TokenID, err = newTokenID(); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.linkRepo.Create(ctx, link); err != nil {
+		return "", err
+	}
+
+	if token == "" {
+		return binding, nil
+	}
+
+	msg := mailer.Message{
+		To:      *user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Someone asked to sign in to the account %q.\n\nOpen the link below on the same device to sign in, it is valid for %s and works once:\n%s\n\nIf it was not you, ignore this message.",
+			user.Username, s.tokenTTL, s.link(token),
+		),
+	}
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(sendCtx, msg); err != nil {
+			log.Printf("failed to send sign-in link to user %d: %v", user.ID, err)
+		}
+	}()
+
+	return binding, nil
+}
+
+// Login consumes the link and signs the user in the same way a password login
+// does, including the second factor step. A link mailed to an address the
+// user has replaced since is rejected.
+func (s *MagicLinkService) Login(ctx context.Context, req dto.MagicLinkLoginRequest, client dto.ClientInfo) (*dto.LoginResult, error) {
+	claims, err := s.jwtService.ValidateMagicLinkToken(req.Token)
+	if err != nil || req.DeviceBinding == "" {
+		return nil, ErrInvalidMagicLink
+	}
+
+	link, err := s.linkRepo.Consume(ctx, claims.ID, hashToken(req.DeviceBinding))
+	if err != nil {
+		return nil, err
+	}
+
+	if link == nil || link.UserID == nil || *link.UserID != claims.UserID {
+		return nil, ErrInvalidMagicLink
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+
+	if !user.EmailVerified() || !strings.EqualFold(*user.Email, link.Email) {
+		return nil, ErrInvalidMagicLink
+	}
+
+	if err := s.linkRepo.InvalidateByUser(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to invalidate other sign-in links: %w", err)
+	}
+
+	client.DeviceName = req.DeviceName
+
+	return s.authService.CompleteLogin(ctx, user, client)
+}
+
+func (s *MagicLinkService) link(token string) string {
+	u, err := url.Parse(s.linkURL)
+	if err != nil {
+		return s.linkURL + "?token=" + url.QueryEscape(token)
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
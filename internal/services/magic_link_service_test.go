@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/mailer"
+	"user-service/internal/repository"
+)
+
+type fakeMagicLinkRepo struct {
+	repository.MagicLinkRepository
+	links []*domain.MagicLink
+}
+
+func (r *fakeMagicLinkRepo) CountSinceByEmail(ctx context.Context, email string, since time.Time) (int, error) {
+	return 0, nil
+}
+
+func (r *fakeMagicLinkRepo) CountSinceByIP(ctx context.Context, ipAddress string, since time.Time) (int, error) {
+	return 0, nil
+}
+
+func (r *fakeMagicLinkRepo) Create(ctx context.Context, link *domain.MagicLink) error {
+	r.links = append(r.links, link)
+	return nil
+}
+
+type discardMailer struct{}
+
+func (discardMailer) Send(ctx context.Context, msg mailer.Message) error {
+	return nil
+}
+
+func TestMagicLinkIsIssuedOnlyForVerifiedAddress(t *testing.T) {
+	verifiedAt := time.Now().UTC()
+
+	tests := []struct {
+		name       string
+		verifiedAt *time.Time
+		issued     bool
+	}{
+		{"verified", &verifiedAt, true},
+		{"unverified", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "alice@example.com"
+			users := newFakeUserRepo(&domain.User{Username: "alice", Email: &email, EmailVerifiedAt: tt.verifiedAt})
+			links := &fakeMagicLinkRepo{}
+
+			keys, err := NewEphemeralKeySet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			jwtService := NewJWTService(config.JWTConfig{Issuer: "user-service-test", Audience: "user-service-test"}, keys)
+
+			service := NewMagicLinkService(config.MagicLinkConfig{
+				URL:         "http://localhost/login/magic-link",
+				TokenTTL:    time.Minute,
+				MaxPerEmail: 5,
+				MaxPerIP:    5,
+				RateWindow:  time.Hour,
+			}, users, links, jwtService, nil, discardMailer{})
+
+			if _, err := service.RequestLink(context.Background(), dto.MagicLinkRequest{Email: email}, dto.ClientInfo{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(links.links) != 1 {
+				t.Fatalf("got %d links, want 1", len(links.links))
+			}
+
+			if issued := links.links[0].UserID != nil; issued != tt.issued {
+				t.Fatalf("sign-in token issued: %v, want %v", issued, tt.issued)
+			}
+		})
+	}
+}
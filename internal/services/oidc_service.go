@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/oidc"
+	"user-service/internal/rbac"
+	"user-service/internal/repository"
+	"user-service/internal/username"
+)
+
+const maxGeneratedUsernameLength = 32
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidOIDCState      = errors.New("login request is invalid or expired, start again")
+	ErrOIDCLoginFailed       = errors.New("identity provider login failed")
+	ErrIdentityAlreadyLinked = errors.New("this external account is already linked to another user")
+)
+
+var usernameDisallowedChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// OIDCService signs users in through external OpenID Connect providers and
+// maps provider accounts to local users.
+type OIDCService struct {
+	providers    map[string]*oidc.Provider
+	stateTTL     time.Duration
+	authService  *AuthService
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+}
+
+func NewOIDCService(
+	cfg config.OIDCConfig,
+	userRepo repository.UserRepository,
+	identityRepo repository.IdentityRepository,
+	authService *AuthService,
+) *OIDCService {
+	providers := make(map[string]*oidc.Provider, len(cfg.Providers))
+	for _, providerCfg := range cfg.Providers {
+		providers[providerCfg.Name] = oidc.NewProvider(providerCfg, nil)
+	}
+
+	return &OIDCService{
+		providers:    providers,
+		stateTTL:     cfg.StateTTL,
+		authService:  authService,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+	}
+}
+
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *OIDCService) StateTTL() time.Duration {
+	return s.stateTTL
+}
+
+// StartLogin prepares an authorization request and returns the provider URL
+// together with the state the callback has to present. A non-nil linkUserID
+// makes the callback link the provider account to that user instead of
+// signing in.
+func (s *OIDCService) StartLogin(ctx context.Context, providerName string, linkUserID *int) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	request := &domain.OIDCAuthRequest{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().UTC().Add(s.stateTTL),
+	}
+
+	if err := s.identityRepo.SaveAuthRequest(ctx, request); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// Callback redeems the authorization code. Known provider accounts sign in
+// as their user. Unknown ones are linked to the user that started a link
+// request, to the local account that verified the same address when the
+// provider is trusted for email, or get a new local user.
+func (s *OIDCService) Callback(ctx context.Context, providerName, state, code string, client dto.ClientInfo) (*dto.OIDCCallbackResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	request, err := s.identityRepo.ConsumeAuthRequest(ctx, hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+
+	if request == nil || request.Provider != providerName {
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Exchange(ctx, code, request.CodeVerifier, request.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	identity, err := s.identityRepo.FindIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.LinkUserID != nil {
+		return s.link(ctx, provider, identity, claims, *request.LinkUserID)
+	}
+
+	var user *domain.User
+
+	if identity != nil {
+		user, err = s.userRepo.GetUserById(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.identityRepo.TouchIdentity(ctx, identity.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		user, err = s.firstLogin(ctx, provider, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = providerName
+	}
+
+	login, err := s.authService.CompleteLogin(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.OIDCCallbackResult{Login: login}, nil
+}
+
+func (s *OIDCService) ListIdentities(ctx context.Context, userID int) ([]dto.IdentityResponse, error) {
+	identities, err := s.identityRepo.ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.IdentityResponse, 0, len(identities))
+	for i := range identities {
+		response = append(response, dto.ToIdentityResponse(&identities[i]))
+	}
+
+	return response, nil
+}
+
+func (s *OIDCService) link(ctx context.Context, provider *oidc.Provider, identity *domain.UserIdentity, claims *oidc.IDClaims, userID int) (*dto.OIDCCallbackResult, error) {
+	if identity != nil {
+		if identity.UserID != userID {
+			return nil, ErrIdentityAlreadyLinked
+		}
+
+		response := dto.ToIdentityResponse(identity)
+		return &dto.OIDCCallbackResult{Linked: &response}, nil
+	}
+
+	identity = newIdentity(provider, claims)
+	identity.UserID = userID
+
+	if err := s.identityRepo.CreateIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	response := dto.ToIdentityResponse(identity)
+	return &dto.OIDCCallbackResult{Linked: &response}, nil
+}
+
+func (s *OIDCService) firstLogin(ctx context.Context, provider *oidc.Provider, claims *oidc.IDClaims) (*domain.User, error) {
+	identity := newIdentity(provider, claims)
+
+	var existing *domain.User
+	if identity.Email != nil {
+		var err error
+		existing, err = s.userRepo.FindByEmail(ctx, *identity.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Anyone can register with any address, so only an address the local
+	// account has proven to own may link it; otherwise whoever registered it
+	// first would keep a password on the provider user's account.
+	if existing != nil && existing.EmailVerifiedAt != nil && provider.TrustEmail() {
+		identity.UserID = existing.ID
+		if err := s.identityRepo.CreateIdentity(ctx, identity); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	username, err := s.availableUsername(ctx, provider.Name(), claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account has no password until the user sets one through the
+	// password reset flow; an empty hash never verifies.
+	user := &domain.User{
+		Username: username,
+		Role:     rbac.RoleUser,
+	}
+
+	// Identities only carry addresses the provider reports as verified.
+	if identity.Email != nil && existing == nil {
+		verifiedAt := time.Now().UTC()
+		user.Email = identity.Email
+		user.EmailVerifiedAt = &verifiedAt
+	}
+
+	if err := s.identityRepo.CreateUserWithIdentity(ctx, user, identity); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// availableUsername derives a username from the provider claims and adds a
+// random suffix while it is taken.
+func (s *OIDCService) availableUsername(ctx context.Context, providerName string, claims *oidc.IDClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = strings.Trim(usernameDisallowedChars.ReplaceAllString(strings.ToLower(base), ""), "._-")
+	if len(base) < username.MinLength || username.IsReserved(base) {
+		base = providerName + "_user"
+	}
+	if len(base) > maxGeneratedUsernameLength-5 {
+		base = base[:maxGeneratedUsernameLength-5]
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 5; attempt++ {
+		existing, err := s.userRepo.FindByUsername(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+
+		if existing == nil {
+			return candidate, nil
+		}
+
+		suffix, err := randomHex(2)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "-" + suffix
+	}
+
+	return "", errors.New("failed to pick a free username")
+}
+
+func newIdentity(provider *oidc.Provider, claims *oidc.IDClaims) *domain.UserIdentity {
+	identity := &domain.UserIdentity{
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+	}
+
+	if claims.Email != "" && claims.EmailVerified {
+		email := claims.Email
+		identity.Email = &email
+	}
+
+	return identity
+}
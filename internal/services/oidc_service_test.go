@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/oidc"
+	"user-service/internal/oidc/oidctest"
+	"user-service/internal/repository"
+)
+
+const testRedirectURL = "http://localhost/api/auth/oidc/mock/callback"
+
+type fakeIdentityRepo struct {
+	repository.IdentityRepository
+	users      *fakeUserRepo
+	requests   map[string]*domain.OIDCAuthRequest
+	identities []*domain.UserIdentity
+}
+
+func (r *fakeIdentityRepo) SaveAuthRequest(ctx context.Context, request *domain.OIDCAuthRequest) error {
+	stored := *request
+	r.requests[request.StateHash] = &stored
+	return nil
+}
+
+func (r *fakeIdentityRepo) ConsumeAuthRequest(ctx context.Context, stateHash string) (*domain.OIDCAuthRequest, error) {
+	request, ok := r.requests[stateHash]
+	if !ok || time.Now().After(request.ExpiresAt) {
+		return nil, nil
+	}
+	delete(r.requests, stateHash)
+	return request, nil
+}
+
+func (r *fakeIdentityRepo) FindIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeIdentityRepo) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	identity.ID = len(r.identities) + 1
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *fakeIdentityRepo) CreateUserWithIdentity(ctx context.Context, user *domain.User, identity *domain.UserIdentity) error {
+	r.users.add(user)
+	identity.UserID = user.ID
+	return r.CreateIdentity(ctx, identity)
+}
+
+func (r *fakeIdentityRepo) TouchIdentity(ctx context.Context, id int) error {
+	return nil
+}
+
+type oidcFixture struct {
+	service    *OIDCService
+	users      *fakeUserRepo
+	identities *fakeIdentityRepo
+	sessions   *fakeSessionRepo
+	client     *http.Client
+}
+
+func newOIDCFixture(t *testing.T, trustEmail bool) *oidcFixture {
+	t.Helper()
+
+	_, server, err := oidctest.NewServer("user-service", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	users := newFakeUserRepo()
+	identities := &fakeIdentityRepo{users: users, requests: make(map[string]*domain.OIDCAuthRequest)}
+	authService, sessions := newTestAuthService(t, users)
+
+	service := NewOIDCService(config.OIDCConfig{
+		Providers: []config.OIDCProviderConfig{{
+			Name:         "mock",
+			DiscoveryURL: server.URL + "/.well-known/openid-configuration",
+			ClientID:     "user-service",
+			ClientSecret: "secret",
+			Scopes:       []string{"openid", "email", "profile"},
+			RedirectURL:  testRedirectURL,
+			TrustEmail:   trustEmail,
+		}},
+		StateTTL: time.Minute,
+	}, users, identities, authService)
+
+	return &oidcFixture{
+		service:    service,
+		users:      users,
+		identities: identities,
+		sessions:   sessions,
+		client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// authorize starts a login and follows it to the provider, returning the
+// state and code the provider sends back to the callback.
+func (f *oidcFixture) authorize(t *testing.T) (string, string) {
+	t.Helper()
+
+	authURL, state, err := f.service.StartLogin(context.Background(), "mock", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := f.client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", resp.StatusCode)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := callback.Query().Get("state"); got != state {
+		t.Fatalf("provider returned state %q, want %q", got, state)
+	}
+
+	return state, callback.Query().Get("code")
+}
+
+func (f *oidcFixture) pendingRequest(t *testing.T, state string) *domain.OIDCAuthRequest {
+	t.Helper()
+
+	request, ok := f.identities.requests[hashToken(state)]
+	if !ok {
+		t.Fatal("auth request is not stored under the state hash")
+	}
+	return request
+}
+
+func TestOIDCLoginCreatesUserAndSession(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	state, code := f.authorize(t)
+
+	request := f.pendingRequest(t, state)
+	if request.Nonce == "" || request.CodeVerifier == "" {
+		t.Fatal("auth request has no nonce or PKCE verifier")
+	}
+
+	result, err := f.service.Callback(context.Background(), "mock", state, code, dto.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Login == nil || result.Login.Tokens == nil {
+		t.Fatalf("callback did not sign in: %+v", result)
+	}
+
+	if len(f.identities.identities) != 1 || f.identities.identities[0].Subject != "oidctest|"+oidctest.DefaultUser {
+		t.Fatalf("unexpected identities: %+v", f.identities.identities)
+	}
+
+	if len(f.sessions.sessions) != 1 || f.sessions.sessions[0].DeviceName != "mock" {
+		t.Fatalf("unexpected sessions: %+v", f.sessions.sessions)
+	}
+
+	if _, ok := f.identities.requests[hashToken(state)]; ok {
+		t.Fatal("auth request was not consumed")
+	}
+}
+
+func TestOIDCCallbackRejectsTamperedState(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	state, code := f.authorize(t)
+
+	_, err := f.service.Callback(context.Background(), "mock", state+"x", code, dto.ClientInfo{})
+	if !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("got %v, want ErrInvalidOIDCState", err)
+	}
+
+	if len(f.sessions.sessions) != 0 {
+		t.Fatal("a session was started")
+	}
+}
+
+func TestOIDCCallbackRejectsWrongNonce(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	state, code := f.authorize(t)
+
+	f.pendingRequest(t, state).Nonce = "another-nonce"
+
+	_, err := f.service.Callback(context.Background(), "mock", state, code, dto.ClientInfo{})
+	if !errors.Is(err, ErrOIDCLoginFailed) || !strings.Contains(err.Error(), "nonce mismatch") {
+		t.Fatalf("got %v, want a nonce mismatch", err)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongVerifier(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	state, code := f.authorize(t)
+
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.pendingRequest(t, state).CodeVerifier = verifier
+
+	_, err = f.service.Callback(context.Background(), "mock", state, code, dto.ClientInfo{})
+	if !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("got %v, want ErrOIDCLoginFailed", err)
+	}
+}
+
+func TestOIDCCallbackRejectsReplay(t *testing.T) {
+	f := newOIDCFixture(t, false)
+	ctx := context.Background()
+	state, code := f.authorize(t)
+
+	if _, err := f.service.Callback(ctx, "mock", state, code, dto.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same callback URL again: the state is already consumed.
+	if _, err := f.service.Callback(ctx, "mock", state, code, dto.ClientInfo{}); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("replayed state: got %v, want ErrInvalidOIDCState", err)
+	}
+
+	// The old code under a fresh state: the provider has already redeemed it.
+	otherState, _ := f.authorize(t)
+	if _, err := f.service.Callback(ctx, "mock", otherState, code, dto.ClientInfo{}); !errors.Is(err, ErrOIDCLoginFailed) {
+		t.Fatalf("replayed code: got %v, want ErrOIDCLoginFailed", err)
+	}
+
+	if len(f.sessions.sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(f.sessions.sessions))
+	}
+}
+
+func TestOIDCFirstLoginLinksVerifiedAccount(t *testing.T) {
+	f := newOIDCFixture(t, true)
+	email := oidctest.DefaultUser + "@example.com"
+	verifiedAt := time.Now().UTC()
+	f.users.add(&domain.User{Username: "alice", Email: &email, EmailVerifiedAt: &verifiedAt})
+
+	state, code := f.authorize(t)
+	if _, err := f.service.Callback(context.Background(), "mock", state, code, dto.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.users.users) != 1 || f.identities.identities[0].UserID != 1 {
+		t.Fatalf("identity was not linked to the verified account: %+v", f.identities.identities)
+	}
+}
+
+func TestOIDCFirstLoginDoesNotLinkUnverifiedAccount(t *testing.T) {
+	f := newOIDCFixture(t, true)
+	email := oidctest.DefaultUser + "@example.com"
+	squatter := &domain.User{Username: "alice", Email: &email}
+	f.users.add(squatter)
+
+	state, code := f.authorize(t)
+	if _, err := f.service.Callback(context.Background(), "mock", state, code, dto.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	identity := f.identities.identities[0]
+	if identity.UserID == squatter.ID {
+		t.Fatal("identity was linked to an account that never verified the address")
+	}
+
+	if user := f.users.users[identity.UserID]; user == nil || user.Email != nil {
+		t.Fatalf("unexpected new user: %+v", user)
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+	"user-service/internal/webauthn"
+)
+
+const defaultPasskeyName = "Passkey"
+
+var (
+	ErrInvalidPasskey           = errors.New("passkey could not be verified")
+	ErrPasskeyNotFound          = errors.New("passkey not found")
+	ErrPasskeyAlreadyRegistered = errors.New("passkey is already registered")
+)
+
+// PasskeyService runs the WebAuthn ceremonies. Every ceremony starts with a
+// challenge stored server side and ends when the signed answer to it comes
+// back; a challenge is consumed before the answer is checked, so it cannot
+// be replayed even by a failed attempt.
+type PasskeyService struct {
+	rp           *webauthn.RelyingParty
+	webauthnRepo repository.WebAuthnRepository
+	userRepo     repository.UserRepository
+	authService  *AuthService
+}
+
+func NewPasskeyService(
+	cfg config.WebAuthnConfig,
+	webauthnRepo repository.WebAuthnRepository,
+	userRepo repository.UserRepository,
+	authService *AuthService,
+) *PasskeyService {
+	return &PasskeyService{
+		rp:           webauthn.NewRelyingParty(cfg.RPID, cfg.RPName, cfg.Origins, cfg.Timeout),
+		webauthnRepo: webauthnRepo,
+		userRepo:     userRepo,
+		authService:  authService,
+	}
+}
+
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID int) (*dto.PasskeyCreationOptionsResponse, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.IsGuest {
+		return nil, ErrGuestNotAllowed
+	}
+
+	credentials, err := s.webauthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for i := range credentials {
+		exclude = append(exclude, webauthn.Descriptor(credentials[i].CredentialID, credentials[i].TransportList()))
+	}
+
+	challenge, err := s.newChallenge(ctx, &userID, domain.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	options := s.rp.CreationOptions(challenge, webauthn.UserEntity{
+		ID:          userHandle(user.ID),
+		Name:        user.Username,
+		DisplayName: user.Username,
+	}, exclude)
+
+	return &dto.PasskeyCreationOptionsResponse{PublicKey: options}, nil
+}
+
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userID int, req dto.FinishPasskeyRegistrationRequest) (*dto.PasskeyResponse, error) {
+	challenge, err := s.consumeChallenge(ctx, req.Credential.Response.ClientDataJSON, domain.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge.stored.UserID == nil || *challenge.stored.UserID != userID {
+		return nil, ErrInvalidPasskey
+	}
+
+	verified, err := s.rp.VerifyRegistration(req.Credential, challenge.raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	credential := &domain.WebAuthnCredential{
+		UserID:         userID,
+		CredentialID:   verified.ID,
+		PublicKey:      verified.PublicKey,
+		Algorithm:      verified.Algorithm,
+		SignCount:      int64(verified.SignCount),
+		AAGUID:         verified.AAGUID,
+		Transports:     strings.Join(verified.Transports, ","),
+		BackupEligible: verified.BackupEligible,
+		BackedUp:       verified.BackedUp,
+		Name:           name,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	if err := s.webauthnRepo.CreateCredential(ctx, credential); err != nil {
+		var conflict *repository.ConflictError
+		if errors.As(err, &conflict) {
+			return nil, ErrPasskeyAlreadyRegistered
+		}
+		return nil, err
+	}
+
+	response := dto.ToPasskeyResponse(credential)
+	return &response, nil
+}
+
+func (s *PasskeyService) List(ctx context.Context, userID int) ([]dto.PasskeyResponse, error) {
+	credentials, err := s.webauthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.PasskeyResponse, 0, len(credentials))
+	for i := range credentials {
+		response = append(response, dto.ToPasskeyResponse(&credentials[i]))
+	}
+
+	return response, nil
+}
+
+func (s *PasskeyService) Delete(ctx context.Context, userID, id int) error {
+	deleted, err := s.webauthnRepo.DeleteCredential(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+// BeginLogin needs no username: the authenticator picks a passkey and its
+// user handle names the account.
+func (s *PasskeyService) BeginLogin(ctx context.Context) (*dto.PasskeyRequestOptionsResponse, error) {
+	challenge, err := s.newChallenge(ctx, nil, domain.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PasskeyRequestOptionsResponse{PublicKey: s.rp.RequestOptions(challenge)}, nil
+}
+
+// FinishLogin checks the assertion and the sign counter and starts a
+// session. The new counter is stored only if it is still the one the check
+// ran against, so two logins with the same assertion cannot both pass.
+func (s *PasskeyService) FinishLogin(ctx context.Context, req dto.FinishPasskeyLoginRequest, client dto.ClientInfo) (*dto.TokenResponse, error) {
+	challenge, err := s.consumeChallenge(ctx, req.Credential.Response.ClientDataJSON, domain.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webauthnRepo.FindCredential(ctx, req.Credential.RawID)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential == nil || string(req.Credential.Response.UserHandle) != string(userHandle(credential.UserID)) {
+		return nil, ErrInvalidPasskey
+	}
+
+	assertion, err := s.rp.VerifyAssertion(req.Credential, challenge.raw, credential.PublicKey, uint32(credential.SignCount))
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegressed) {
+			log.Printf("passkey %d of user %d reported a sign counter that did not increase, it may be cloned", credential.ID, credential.UserID)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	recorded, err := s.webauthnRepo.RecordUse(ctx, credential.ID, credential.SignCount, int64(assertion.SignCount), assertion.BackedUp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !recorded {
+		return nil, ErrInvalidPasskey
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, credential.UserID)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	client.DeviceName = req.DeviceName
+
+	return s.authService.CompletePasskeyLogin(ctx, user, client)
+}
+
+type pendingChallenge struct {
+	raw    []byte
+	stored *domain.WebAuthnChallenge
+}
+
+func (s *PasskeyService) newChallenge(ctx context.Context, userID *int, ceremony string) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	err = s.webauthnRepo.CreateChallenge(ctx, &domain.WebAuthnChallenge{
+		ChallengeHash: hashToken(string(challenge)),
+		UserID:        userID,
+		Ceremony:      ceremony,
+		ExpiresAt:     now.Add(s.rp.Timeout()),
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (s *PasskeyService) consumeChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (*pendingChallenge, error) {
+	raw, err := webauthn.ClientChallenge(clientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	stored, err := s.webauthnRepo.ConsumeChallenge(ctx, hashToken(string(raw)), ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored == nil {
+		return nil, ErrInvalidPasskey
+	}
+
+	return &pendingChallenge{raw: raw, stored: stored}, nil
+}
+
+// userHandle is the WebAuthn user.id of an account. It only has to be stable
+// and unique, and the numeric id carries no personal data.
+func userHandle(userID int) []byte {
+	return []byte(strconv.Itoa(userID))
+}
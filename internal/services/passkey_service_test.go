@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+	"user-service/internal/webauthn/webauthntest"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:8080"
+)
+
+type fakeWebAuthnRepo struct {
+	repository.WebAuthnRepository
+	challenges  map[string]*domain.WebAuthnChallenge
+	credentials []*domain.WebAuthnCredential
+}
+
+func (r *fakeWebAuthnRepo) CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	stored := *challenge
+	r.challenges[challenge.ChallengeHash] = &stored
+	return nil
+}
+
+func (r *fakeWebAuthnRepo) ConsumeChallenge(ctx context.Context, challengeHash, ceremony string) (*domain.WebAuthnChallenge, error) {
+	challenge, ok := r.challenges[challengeHash]
+	if !ok || challenge.Ceremony != ceremony || time.Now().After(challenge.ExpiresAt) {
+		return nil, nil
+	}
+	delete(r.challenges, challengeHash)
+	return challenge, nil
+}
+
+func (r *fakeWebAuthnRepo) CreateCredential(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	credential.ID = len(r.credentials) + 1
+	r.credentials = append(r.credentials, credential)
+	return nil
+}
+
+func (r *fakeWebAuthnRepo) FindCredential(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error) {
+	for _, credential := range r.credentials {
+		if string(credential.CredentialID) == string(credentialID) {
+			stored := *credential
+			return &stored, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeWebAuthnRepo) ListCredentials(ctx context.Context, userID int) ([]domain.WebAuthnCredential, error) {
+	var credentials []domain.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, *credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *fakeWebAuthnRepo) RecordUse(ctx context.Context, id int, previousCount, signCount int64, backedUp bool) (bool, error) {
+	for _, credential := range r.credentials {
+		if credential.ID == id && credential.SignCount == previousCount {
+			credential.SignCount = signCount
+			credential.BackedUp = backedUp
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type passkeyFixture struct {
+	service       *PasskeyService
+	credentials   *fakeWebAuthnRepo
+	sessions      *fakeSessionRepo
+	authenticator *webauthntest.Authenticator
+	user          *domain.User
+}
+
+func newPasskeyFixture(t *testing.T) *passkeyFixture {
+	t.Helper()
+
+	user := &domain.User{ID: 1, Username: "alice"}
+	users := newFakeUserRepo(user)
+	credentials := &fakeWebAuthnRepo{challenges: make(map[string]*domain.WebAuthnChallenge)}
+	authService, sessions := newTestAuthService(t, users)
+
+	service := NewPasskeyService(config.WebAuthnConfig{
+		RPID:    testRPID,
+		RPName:  "User Service",
+		Origins: []string{testOrigin},
+		Timeout: time.Minute,
+	}, credentials, users, authService)
+
+	return &passkeyFixture{
+		service:       service,
+		credentials:   credentials,
+		sessions:      sessions,
+		authenticator: webauthntest.NewAuthenticator(testOrigin),
+		user:          user,
+	}
+}
+
+func (f *passkeyFixture) register(t *testing.T) *dto.PasskeyResponse {
+	t.Helper()
+	ctx := context.Background()
+
+	options, err := f.service.BeginRegistration(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := f.authenticator.Create(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passkey, err := f.service.FinishRegistration(ctx, f.user.ID, dto.FinishPasskeyRegistrationRequest{Credential: *created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passkey
+}
+
+func (f *passkeyFixture) login(t *testing.T) (*dto.TokenResponse, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	options, err := f.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := f.authenticator.Get(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f.service.FinishLogin(ctx, dto.FinishPasskeyLoginRequest{Credential: *answer}, dto.ClientInfo{})
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	f := newPasskeyFixture(t)
+
+	if passkey := f.register(t); passkey.Name != defaultPasskeyName {
+		t.Fatalf("got name %q, want %q", passkey.Name, defaultPasskeyName)
+	}
+
+	for want := int64(2); want <= 3; want++ {
+		tokens, err := f.login(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tokens.AccessToken == "" {
+			t.Fatal("login issued no access token")
+		}
+		if got := f.credentials.credentials[0].SignCount; got != want {
+			t.Fatalf("stored sign count %d, want %d", got, want)
+		}
+	}
+
+	if len(f.sessions.sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(f.sessions.sessions))
+	}
+}
+
+func TestPasskeyLoginRejectsRegressedSignCount(t *testing.T) {
+	f := newPasskeyFixture(t)
+	f.register(t)
+
+	if _, err := f.login(t); err != nil {
+		t.Fatal(err)
+	}
+
+	// A clone made before the last login still answers with its old counter.
+	stored := f.credentials.credentials[0]
+	if err := f.authenticator.SetSignCount(testRPID, stored.CredentialID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.login(t); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+
+	if stored.SignCount != 2 || len(f.sessions.sessions) != 1 {
+		t.Fatalf("regressed login was recorded: sign count %d, %d sessions", stored.SignCount, len(f.sessions.sessions))
+	}
+}
+
+func TestPasskeyLoginAcceptsNonCountingAuthenticator(t *testing.T) {
+	f := newPasskeyFixture(t)
+	f.authenticator.Counting = false
+	f.register(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.login(t); err != nil {
+			t.Fatalf("login %d: %v", i+1, err)
+		}
+	}
+
+	if got := f.credentials.credentials[0].SignCount; got != 0 {
+		t.Fatalf("stored sign count %d, want 0", got)
+	}
+}
+
+func TestPasskeyLoginRejectsUnknownChallenge(t *testing.T) {
+	f := newPasskeyFixture(t)
+	f.register(t)
+	ctx := context.Background()
+
+	options, err := f.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Answer a challenge the server never issued.
+	options.PublicKey.Challenge = append([]byte(nil), options.PublicKey.Challenge...)
+	options.PublicKey.Challenge[0] ^= 0xff
+
+	answer, err := f.authenticator.Get(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.service.FinishLogin(ctx, dto.FinishPasskeyLoginRequest{Credential: *answer}, dto.ClientInfo{}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyLoginRejectsReplayedAssertion(t *testing.T) {
+	f := newPasskeyFixture(t)
+	f.register(t)
+	ctx := context.Background()
+
+	options, err := f.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := f.authenticator.Get(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := dto.FinishPasskeyLoginRequest{Credential: *answer}
+	if _, err := f.service.FinishLogin(ctx, req, dto.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.service.FinishLogin(ctx, req, dto.ClientInfo{}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyRegistrationRejectsWrongOrigin(t *testing.T) {
+	f := newPasskeyFixture(t)
+	ctx := context.Background()
+
+	options, err := f.service.BeginRegistration(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := webauthntest.NewAuthenticator("https://login.example.net").Create(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.service.FinishRegistration(ctx, f.user.ID, dto.FinishPasskeyRegistrationRequest{Credential: *created})
+	if !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+
+	if len(f.credentials.credentials) != 0 {
+		t.Fatal("a passkey from another origin was stored")
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/mailer"
+	"user-service/internal/password"
+	"user-service/internal/repository"
+)
+
+const mailSendTimeout = 30 * time.Second
+
+var ErrInvalidResetToken = errors.New("reset token is invalid or expired")
+
+type PasswordResetService struct {
+	authService   *AuthService
+	apiKeyService *APIKeyService
+	policy        *password.Policy
+	hasher        password.Hasher
+	userRepo      repository.UserRepository
+	resetRepo     repository.PasswordResetRepository
+	mailer        mailer.Mailer
+	resetURL      string
+	tokenTTL      time.Duration
+}
+
+func NewPasswordResetService(
+	cfg config.PasswordResetConfig,
+	userRepo repository.UserRepository,
+	resetRepo repository.PasswordResetRepository,
+	authService *AuthService,
+	apiKeyService *APIKeyService,
+	policy *password.Policy,
+	hasher password.Hasher,
+	mailer mailer.Mailer,
+) *PasswordResetService {
+	return &PasswordResetService{
+		authService:   authService,
+		apiKeyService: apiKeyService,
+		policy:        policy,
+		hasher:        hasher,
+		userRepo:      userRepo,
+		resetRepo:     resetRepo,
+		mailer:        mailer,
+		resetURL:      cfg.URL,
+		tokenTTL:      cfg.TokenTTL,
+	}
+}
+
+// RequestReset mails a single-use reset link to the owner of the address,
+// but only once they have verified it: anyone can put any address on an
+// account. It reports success whether or not the address is known, and sends
+// the mail in the background so the response time does not reveal it either.
+func (s *PasswordResetService) RequestReset(ctx context.Context, req dto.ForgotPasswordRequest) error {
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		return err
+	}
+
+	if user == nil || !user.EmailVerified() {
+		return nil
+	}
+
+	if err := s.resetRepo.InvalidateByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to invalidate previous reset tokens: %w", err)
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+
+	resetToken := &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(s.tokenTTL),
+	}
+
+	if err := s.resetRepo.Create(ctx, resetToken); err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      *user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Someone requested a password reset for the account %q.\n\nOpen the link below to choose a new password, it is valid for %s:\n%s\n\nIf it was not you, ignore this message.",
+			user.Username, s.tokenTTL, s.resetLink(token),
+		),
+	}
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(sendCtx, msg); err != nil {
+			log.Printf("failed to send password reset mail to user %d: %v", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword checks the new password against the policy before the token
+// is consumed, so a rejected password does not burn the link from the mail.
+// A reset is how accounts are recovered after a compromise, so besides all
+// sessions it also revokes the API keys of the user.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+	tokenHash := hashToken(req.Token)
+
+	resetToken, err := s.resetRepo.FindValid(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if resetToken == nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, resetToken.UserID)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.policy.Validate(user.Username, req.NewPassword); err != nil {
+		return err
+	}
+
+	userID, err := s.resetRepo.Consume(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if userID == 0 {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := s.authService.RevokeAllUserAccess(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.apiKeyService.RevokeAllForUser(ctx, userID)
+}
+
+func (s *PasswordResetService) resetLink(token string) string {
+	u, err := url.Parse(s.resetURL)
+	if err != nil {
+		return s.resetURL + "?token=" + url.QueryEscape(token)
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
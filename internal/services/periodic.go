@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPeriodically runs a cleanup job right away and then every interval
+// until ctx is done. The job reports how many records it removed.
+func runPeriodically(ctx context.Context, interval time.Duration, name string, job func(context.Context) (int64, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := job(ctx)
+		if err != nil {
+			log.Printf("%s failed: %v", name, err)
+		} else if removed > 0 {
+			log.Printf("%s: removed %d", name, removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
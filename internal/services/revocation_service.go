@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+	"user-service/internal/repository"
+)
+
+const revocationCacheSweepSize = 10000
+
+// CachedRevocationStore keeps revocation lookups in process memory so the
+// auth middleware does not hit the database on every request. Revocations
+// made through this instance are visible immediately, ones made by other
+// instances become visible once the cached "not revoked" answer expires.
+type CachedRevocationStore struct {
+	store repository.RevocationRepository
+	ttl   time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]cachedRevocation
+	users    map[int]cachedUserRevocation
+	sessions map[int]cachedRevocation
+}
+
+type cachedRevocation struct {
+	revoked   bool
+	validTill time.Time
+}
+
+type cachedUserRevocation struct {
+	generation int
+	validTill  time.Time
+}
+
+func NewCachedRevocationStore(store repository.RevocationRepository, ttl time.Duration) *CachedRevocationStore {
+	return &CachedRevocationStore{
+		store:    store,
+		ttl:      ttl,
+		tokens:   make(map[string]cachedRevocation),
+		users:    make(map[int]cachedUserRevocation),
+		sessions: make(map[int]cachedRevocation),
+	}
+}
+
+func (s *CachedRevocationStore) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	if err := s.store.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked()
+	s.tokens[jti] = cachedRevocation{revoked: true, validTill: expiresAt}
+
+	return nil
+}
+
+func (s *CachedRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	entry, ok := s.tokens[jti]
+	s.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.validTill) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := s.store.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked()
+	if revoked {
+		// The expiry of the token is unknown here, keep it for a long time;
+		// expired tokens are rejected before the denylist is consulted.
+		s.tokens[jti] = cachedRevocation{revoked: true, validTill: time.Now().Add(24 * time.Hour)}
+	} else {
+		s.tokens[jti] = cachedRevocation{revoked: false, validTill: time.Now().Add(s.ttl)}
+	}
+
+	return revoked, nil
+}
+
+func (s *CachedRevocationStore) RevokeUserTokens(ctx context.Context, userID int, revokedAt time.Time) (int, error) {
+	generation, err := s.store.RevokeUserTokens(ctx, userID, revokedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID] = cachedUserRevocation{generation: generation, validTill: time.Now().Add(s.ttl)}
+
+	return generation, nil
+}
+
+func (s *CachedRevocationStore) UserTokenGeneration(ctx context.Context, userID int) (int, error) {
+	s.mu.RLock()
+	entry, ok := s.users[userID]
+	s.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.validTill) {
+		return entry.generation, nil
+	}
+
+	generation, err := s.store.UserTokenGeneration(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.users) >= revocationCacheSweepSize {
+		s.users = make(map[int]cachedUserRevocation)
+	}
+	s.users[userID] = cachedUserRevocation{generation: generation, validTill: time.Now().Add(s.ttl)}
+
+	return generation, nil
+}
+
+func (s *CachedRevocationStore) RevokeSession(ctx context.Context, sessionID int) error {
+	if err := s.store.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked()
+	s.sessions[sessionID] = cachedRevocation{revoked: true, validTill: time.Now().Add(24 * time.Hour)}
+
+	return nil
+}
+
+func (s *CachedRevocationStore) IsSessionRevoked(ctx context.Context, sessionID int) (bool, error) {
+	s.mu.RLock()
+	entry, ok := s.sessions[sessionID]
+	s.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.validTill) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := s.store.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked()
+	if revoked {
+		// A revoked session stays revoked; a day outlives any access token.
+		s.sessions[sessionID] = cachedRevocation{revoked: true, validTill: time.Now().Add(24 * time.Hour)}
+	} else {
+		s.sessions[sessionID] = cachedRevocation{revoked: false, validTill: time.Now().Add(s.ttl)}
+	}
+
+	return revoked, nil
+}
+
+func (s *CachedRevocationStore) sweepLocked() {
+	now := time.Now()
+
+	if len(s.sessions) >= revocationCacheSweepSize {
+		for id, entry := range s.sessions {
+			if now.After(entry.validTill) {
+				delete(s.sessions, id)
+			}
+		}
+	}
+
+	if len(s.tokens) < revocationCacheSweepSize {
+		return
+	}
+
+	for jti, entry := range s.tokens {
+		if now.After(entry.validTill) {
+			delete(s.tokens, jti)
+		}
+	}
+}
+
+// RevocationService decides whether an access token was revoked before its
+// expiry, either individually by jti, together with the other tokens of its
+// session, or together with every token of a user.
+type RevocationService struct {
+	store repository.RevocationRepository
+}
+
+func NewRevocationService(store repository.RevocationRepository) *RevocationService {
+	return &RevocationService{
+		store: store,
+	}
+}
+
+func (s *RevocationService) RevokeAccessToken(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	return s.store.RevokeToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
+// RevokeSession invalidates every access token issued for the session. The
+// session itself is revoked through SessionRepository; this makes the change
+// visible to the token check of this instance right away.
+func (s *RevocationService) RevokeSession(ctx context.Context, sessionID int) error {
+	return s.store.RevokeSession(ctx, sessionID)
+}
+
+// RevokeAllForUser invalidates every token issued to the user so far by
+// starting a new token generation. Timestamps in JWTs have second precision
+// and cannot tell a token issued just before the revocation from one issued
+// just after it, the generation can.
+func (s *RevocationService) RevokeAllForUser(ctx context.Context, userID int) error {
+	_, err := s.store.RevokeUserTokens(ctx, userID, time.Now().UTC())
+	return err
+}
+
+// TokenGeneration is the generation new access tokens of the user are
+// issued in. Right after a revocation made by another instance it may still
+// be the old one; such a token stops working once the cache catches up and
+// is replaced on the next refresh.
+func (s *RevocationService) TokenGeneration(ctx context.Context, userID int) (int, error) {
+	return s.store.UserTokenGeneration(ctx, userID)
+}
+
+func (s *RevocationService) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := s.store.IsTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
+	// Impersonation tokens belong to no session.
+	if claims.SessionID != 0 {
+		revoked, err := s.store.IsSessionRevoked(ctx, claims.SessionID)
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
+	generation, err := s.store.UserTokenGeneration(ctx, claims.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	return claims.Gen < generation, nil
+}
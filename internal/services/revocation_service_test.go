@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type memoryRevocationStore struct {
+	tokens      map[string]bool
+	generations map[int]int
+	sessions    map[int]bool
+}
+
+func newMemoryRevocationStore() *memoryRevocationStore {
+	return &memoryRevocationStore{
+		tokens:      make(map[string]bool),
+		generations: make(map[int]int),
+		sessions:    make(map[int]bool),
+	}
+}
+
+func (m *memoryRevocationStore) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	m.tokens[jti] = true
+	return nil
+}
+
+func (m *memoryRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return m.tokens[jti], nil
+}
+
+func (m *memoryRevocationStore) RevokeUserTokens(ctx context.Context, userID int, revokedAt time.Time) (int, error) {
+	m.generations[userID]++
+	return m.generations[userID], nil
+}
+
+func (m *memoryRevocationStore) UserTokenGeneration(ctx context.Context, userID int) (int, error) {
+	return m.generations[userID], nil
+}
+
+func (m *memoryRevocationStore) RevokeSession(ctx context.Context, sessionID int) error {
+	m.sessions[sessionID] = true
+	return nil
+}
+
+func (m *memoryRevocationStore) IsSessionRevoked(ctx context.Context, sessionID int) (bool, error) {
+	return m.sessions[sessionID], nil
+}
+
+func TestRevokeAllForUserKeepsTokensIssuedInTheSameSecond(t *testing.T) {
+	ctx := context.Background()
+	service := NewRevocationService(NewCachedRevocationStore(newMemoryRevocationStore(), time.Minute))
+
+	issue := func() *Claims {
+		generation, err := service.TokenGeneration(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Claims{UserID: 1, Gen: generation}
+	}
+
+	before := issue()
+
+	if err := service.RevokeAllForUser(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	after := issue()
+
+	// Both tokens would carry the same whole-second iat.
+	if revoked, _ := service.IsRevoked(ctx, before); !revoked {
+		t.Error("token issued before the revocation is still accepted")
+	}
+
+	if revoked, _ := service.IsRevoked(ctx, after); revoked {
+		t.Error("token issued right after the revocation is rejected")
+	}
+
+	if err := service.RevokeAllForUser(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, _ := service.IsRevoked(ctx, after); !revoked {
+		t.Error("second revocation does not cover the token issued after the first one")
+	}
+
+	if revoked, _ := service.IsRevoked(ctx, &Claims{UserID: 2}); revoked {
+		t.Error("tokens of other users are revoked")
+	}
+}
+
+func TestRevokeAccessTokenRejectsOnlyThatToken(t *testing.T) {
+	ctx := context.Background()
+	service := NewRevocationService(newMemoryRevocationStore())
+
+	revoked := &Claims{UserID: 1}
+	revoked.ID = "a"
+	revoked.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	other := &Claims{UserID: 1}
+	other.ID = "b"
+
+	if err := service.RevokeAccessToken(ctx, revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := service.IsRevoked(ctx, revoked); !ok {
+		t.Error("revoked token is accepted")
+	}
+
+	if ok, _ := service.IsRevoked(ctx, other); ok {
+		t.Error("another token of the user is rejected")
+	}
+}
+
+func TestRevokeSessionRejectsItsTokens(t *testing.T) {
+	ctx := context.Background()
+	service := NewRevocationService(NewCachedRevocationStore(newMemoryRevocationStore(), time.Minute))
+
+	first := &Claims{UserID: 1, SessionID: 1}
+	second := &Claims{UserID: 1, SessionID: 1}
+	other := &Claims{UserID: 1, SessionID: 2}
+
+	// Cache the answer for the session before it is revoked.
+	if revoked, _ := service.IsRevoked(ctx, first); revoked {
+		t.Fatal("token of an active session is rejected")
+	}
+
+	if err := service.RevokeSession(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, claims := range []*Claims{first, second} {
+		if revoked, _ := service.IsRevoked(ctx, claims); !revoked {
+			t.Error("token of the revoked session is accepted")
+		}
+	}
+
+	if revoked, _ := service.IsRevoked(ctx, other); revoked {
+		t.Error("token of another session is rejected")
+	}
+}
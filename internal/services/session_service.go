@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+)
+
+var ErrSessionNotFound = errors.New("session is not found")
+
+type SessionService struct {
+	sessionRepo       repository.SessionRepository
+	revocationService *RevocationService
+}
+
+func NewSessionService(sessionRepo repository.SessionRepository, revocationService *RevocationService) *SessionService {
+	return &SessionService{
+		sessionRepo:       sessionRepo,
+		revocationService: revocationService,
+	}
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, userID, currentSessionID int) ([]dto.SessionResponse, error) {
+	sessions, err := s.sessionRepo.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = dto.ToSessionResponse(&session, currentSessionID)
+	}
+
+	return response, nil
+}
+
+// RevokeSession ends the session, so neither its refresh token nor the
+// access tokens already issued for it work any more.
+func (s *SessionService) RevokeSession(ctx context.Context, userID, sessionID int) error {
+	revoked, err := s.sessionRepo.Revoke(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	return s.revocationService.RevokeSession(ctx, sessionID)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"user-service/internal/proofstore"
+)
+
+var (
+	ErrProofRequired   = errors.New("this task needs a proof: a text, a link or a file")
+	ErrProofTooLarge   = errors.New("proof file is too large")
+	ErrProofFileType   = errors.New("proof file must be a PNG, JPEG or WebP image or a PDF document")
+	ErrProofFileAbsent = errors.New("submission has no proof file")
+)
+
+// proofFileTypes maps the accepted content types, as sniffed from the file
+// itself, to the extension of the stored copy. The type the client declares
+// is never trusted.
+var proofFileTypes = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// ProofFile is a file uploaded as proof. Size is what the client declared;
+// the stored copy is checked against the limit again while it is written.
+type ProofFile struct {
+	Name    string
+	Size    int64
+	Content io.Reader
+}
+
+// StoredProof is a proof file as kept in the proof store.
+type StoredProof struct {
+	Name        string
+	ContentType string
+	Content     io.ReadCloser
+}
+
+// saveProof checks the type and size of the file and stores it under a new
+// random key.
+func saveProof(ctx context.Context, store proofstore.Store, maxBytes int64, file *ProofFile) (key, contentType string, err error) {
+	if file.Size > maxBytes {
+		return "", "", ErrProofTooLarge
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file.Content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", err
+	}
+
+	contentType = http.DetectContentType(head[:n])
+	ext, ok := proofFileTypes[contentType]
+	if !ok {
+		return "", "", ErrProofFileType
+	}
+
+	key, err = randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	content := &countingReader{r: io.LimitReader(io.MultiReader(bytes.NewReader(head[:n]), file.Content), maxBytes+1)}
+	if err := store.Save(ctx, key+ext, content); err != nil {
+		return "", "", err
+	}
+
+	if content.n > maxBytes {
+		deleteProof(ctx, store, key+ext)
+		return "", "", ErrProofTooLarge
+	}
+
+	return key + ext, contentType, nil
+}
+
+// deleteProof removes a proof file that is no longer referenced. A failure
+// only leaves an orphaned file behind, so it is logged rather than returned.
+func deleteProof(ctx context.Context, store proofstore.Store, key string) {
+	if err := store.Delete(ctx, key); err != nil {
+		log.Printf("failed to delete proof file %s: %v", key, err)
+	}
+}
+
+// proofFileName keeps the base name the user gave the file, without control
+// characters, for moderators to see. It is never used as a path.
+func proofFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+
+	if name == "." || name == "/" {
+		name = ""
+	}
+
+	// Keep the end of long names, where the extension is.
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[len(runes)-255:])
+	}
+
+	return strings.TrimSpace(name)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
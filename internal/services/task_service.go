@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"user-service/internal/domain"
+	"user-service/internal/dto"
+	"user-service/internal/proofstore"
+	"user-service/internal/repository"
+)
+
+var (
+	ErrTaskNotFound        = errors.New("task not found")
+	ErrInvalidTaskTitle    = errors.New("task title must not be blank")
+	ErrInvalidTaskCategory = errors.New("task category must be a slug of lowercase letters, digits, '-' and '_'")
+	ErrSubmissionNotFound  = errors.New("submission not found")
+	ErrSubmissionReviewed  = errors.New("submission has already been reviewed")
+	ErrOwnSubmission       = errors.New("moderators cannot review their own submissions")
+)
+
+var taskCategoryPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+type TaskService struct {
+	taskRepo repository.TaskRepository
+	proofs   proofstore.Store
+}
+
+func NewTaskService(taskRepo repository.TaskRepository, proofs proofstore.Store) *TaskService {
+	return &TaskService{
+		taskRepo: taskRepo,
+		proofs:   proofs,
+	}
+}
+
+func (s *TaskService) Create(ctx context.Context, req dto.CreateTaskRequest) (*dto.TaskResponse, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, ErrInvalidTaskTitle
+	}
+
+	category, err := normalizeTaskCategory(req.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := req.VerificationMode
+	if mode == "" {
+		mode = domain.TaskVerificationAuto
+	}
+
+	now := time.Now().UTC()
+	task := &domain.Task{
+		Title:            title,
+		Description:      strings.TrimSpace(req.Description),
+		Category:         category,
+		Points:           req.Points,
+		VerificationMode: mode,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if err := s.taskRepo.CreateTask(ctx, task); err != nil {
+		return nil, err
+	}
+
+	response := dto.ToTaskResponse(task)
+	return &response, nil
+}
+
+// Update edits a task, archived ones included. Points changed here apply to
+// later completions only; points already awarded stay as they are.
+func (s *TaskService) Update(ctx context.Context, id int, req dto.UpdateTaskRequest) (*dto.TaskResponse, error) {
+	task, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, ErrInvalidTaskTitle
+		}
+		task.Title = title
+	}
+
+	if req.Description != nil {
+		task.Description = strings.TrimSpace(*req.Description)
+	}
+
+	if req.Category != nil {
+		if task.Category, err = normalizeTaskCategory(*req.Category); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Points != nil {
+		task.Points = *req.Points
+	}
+
+	if req.VerificationMode != nil {
+		task.VerificationMode = *req.VerificationMode
+	}
+
+	task.UpdatedAt = time.Now().UTC()
+
+	if err := s.taskRepo.UpdateTask(ctx, task); err != nil {
+		return nil, err
+	}
+
+	response := dto.ToTaskResponse(task)
+	return &response, nil
+}
+
+func (s *TaskService) Archive(ctx context.Context, id int) (*dto.TaskResponse, error) {
+	if _, err := s.find(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.taskRepo.ArchiveTask(ctx, id); err != nil {
+		return nil, err
+	}
+
+	task, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := dto.ToTaskResponse(task)
+	return &response, nil
+}
+
+func (s *TaskService) ListAll(ctx context.Context, includeArchived bool) ([]dto.TaskResponse, error) {
+	tasks, err := s.taskRepo.ListTasks(ctx, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TaskResponse, 0, len(tasks))
+	for i := range tasks {
+		response = append(response, dto.ToTaskResponse(&tasks[i]))
+	}
+
+	return response, nil
+}
+
+// Catalog lists the active tasks for the user, each with the status of the
+// user's submission, a page at a time in id order.
+func (s *TaskService) Catalog(ctx context.Context, userID int, query dto.TaskCatalogQuery) (*dto.TaskCatalogResponse, error) {
+	afterID, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := pageSize(query.Limit)
+
+	filter := repository.TaskCatalogFilter{
+		AfterID: afterID,
+		Limit:   limit + 1,
+	}
+
+	if query.Category != "" {
+		if filter.Category, err = normalizeTaskCategory(query.Category); err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Status != "all" {
+		filter.Status = query.Status
+	}
+
+	tasks, err := s.taskRepo.ListCatalog(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.TaskCatalogResponse{Tasks: make([]dto.CatalogTaskResponse, 0, len(tasks))}
+
+	// One row more than the page size is fetched to know whether another
+	// page follows.
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		response.NextCursor = encodeCursor(tasks[len(tasks)-1].ID)
+	}
+
+	for i := range tasks {
+		response.Tasks = append(response.Tasks, dto.ToCatalogTaskResponse(&tasks[i]))
+	}
+
+	return response, nil
+}
+
+// ListSubmissions returns the submissions in the given status, pending by
+// default, oldest first so the review queue is worked in order.
+func (s *TaskService) ListSubmissions(ctx context.Context, query dto.SubmissionQuery) (*dto.TaskSubmissionsResponse, error) {
+	afterID, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	status := query.Status
+	if status == "" {
+		status = domain.SubmissionPending
+	}
+
+	limit := pageSize(query.Limit)
+
+	submissions, err := s.taskRepo.ListSubmissions(ctx, repository.SubmissionFilter{
+		Status:  status,
+		AfterID: afterID,
+		Limit:   limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.TaskSubmissionsResponse{Submissions: make([]dto.ReviewSubmissionResponse, 0, len(submissions))}
+
+	if len(submissions) > limit {
+		submissions = submissions[:limit]
+		response.NextCursor = encodeCursor(submissions[len(submissions)-1].ID)
+	}
+
+	for i := range submissions {
+		response.Submissions = append(response.Submissions, dto.ToReviewSubmissionResponse(&submissions[i]))
+	}
+
+	return response, nil
+}
+
+// Approve accepts a pending submission and credits the task's current
+// points to its author.
+func (s *TaskService) Approve(ctx context.Context, reviewerID, id int) (*dto.ReviewSubmissionResponse, error) {
+	submission, err := s.review(ctx, reviewerID, id, func(submission *domain.UserTask, now time.Time) (bool, error) {
+		submission.Status = domain.SubmissionApproved
+		submission.CompletedAt = &now
+
+		return s.taskRepo.ApproveAndCredit(ctx, submission, &domain.BalanceTransaction{
+			UserID: &submission.UserID,
+			Amount: submission.Task.Points,
+			Reason: domain.BalanceReasonTaskCompleted,
+			TaskID: &submission.TaskID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := dto.ToReviewSubmissionResponse(submission)
+	return &response, nil
+}
+
+// Reject declines a pending submission. The user sees the reason and may
+// submit the task again.
+func (s *TaskService) Reject(ctx context.Context, reviewerID, id int, reason string) (*dto.ReviewSubmissionResponse, error) {
+	submission, err := s.review(ctx, reviewerID, id, func(submission *domain.UserTask, now time.Time) (bool, error) {
+		submission.Status = domain.SubmissionRejected
+		submission.RejectionReason = strings.TrimSpace(reason)
+
+		return s.taskRepo.ReviewSubmission(ctx, submission)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := dto.ToReviewSubmissionResponse(submission)
+	return &response, nil
+}
+
+// OpenProof returns the file attached to a submission. The caller closes
+// its content.
+func (s *TaskService) OpenProof(ctx context.Context, id int) (*StoredProof, error) {
+	submission, err := s.findSubmission(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !submission.HasProofFile() {
+		return nil, ErrProofFileAbsent
+	}
+
+	content, err := s.proofs.Open(ctx, submission.ProofFileKey)
+	if errors.Is(err, proofstore.ErrNotFound) {
+		return nil, ErrProofFileAbsent
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &StoredProof{
+		Name:        submission.ProofFileName,
+		ContentType: submission.ProofContentType,
+		Content:     content,
+	}, nil
+}
+
+// review checks that the reviewer may decide the submission and lets decide
+// record the outcome. decide reports false when someone else was faster.
+func (s *TaskService) review(ctx context.Context, reviewerID, id int, decide func(*domain.UserTask, time.Time) (bool, error)) (*domain.UserTask, error) {
+	submission, err := s.findSubmission(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if submission.UserID == reviewerID {
+		return nil, ErrOwnSubmission
+	}
+
+	if submission.Status != domain.SubmissionPending {
+		return nil, ErrSubmissionReviewed
+	}
+
+	now := time.Now().UTC()
+	submission.ReviewedBy = &reviewerID
+	submission.ReviewedAt = &now
+	reviewed, err := decide(submission, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reviewed {
+		return nil, ErrSubmissionReviewed
+	}
+
+	return submission, nil
+}
+
+func (s *TaskService) findSubmission(ctx context.Context, id int) (*domain.UserTask, error) {
+	submission, err := s.taskRepo.GetSubmission(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if submission == nil {
+		return nil, ErrSubmissionNotFound
+	}
+
+	return submission, nil
+}
+
+func normalizeTaskCategory(category string) (string, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if category == "" {
+		return domain.DefaultTaskCategory, nil
+	}
+
+	if !taskCategoryPattern.MatchString(category) {
+		return "", ErrInvalidTaskCategory
+	}
+
+	return category, nil
+}
+
+func (s *TaskService) find(ctx context.Context, id int) (*domain.Task, error) {
+	task, err := s.taskRepo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+
+	return task, nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user-service/internal/cryptobox"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+	"user-service/internal/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	totpAllowedSkew   = 1
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor enrollment was not started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TwoFactorService struct {
+	twoFactorRepo repository.TwoFactorRepository
+	box           *cryptobox.Box
+	issuer        string
+}
+
+func NewTwoFactorService(twoFactorRepo repository.TwoFactorRepository, box *cryptobox.Box, issuer string) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		box:           box,
+		issuer:        issuer,
+	}
+}
+
+// Enroll creates a new TOTP secret. It stays inactive until Confirm proves
+// the user has added it to an authenticator app.
+func (s *TwoFactorService) Enroll(ctx context.Context, userID int, username string) (*dto.TwoFactorEnrollResponse, error) {
+	enabled, err := s.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.box.Seal([]byte(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
+	if err := s.twoFactorRepo.SavePendingTOTP(ctx, userID, encrypted); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.issuer, username, secret),
+	}, nil
+}
+
+func (s *TwoFactorService) Confirm(ctx context.Context, userID int, code string) (*dto.RecoveryCodesResponse, error) {
+	settings, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if settings.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, err := s.validateTOTP(settings.SecretEncrypted, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.ConfirmTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, userID int, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	return s.twoFactorRepo.DeleteTOTP(ctx, userID)
+}
+
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) (*dto.RecoveryCodesResponse, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *TwoFactorService) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	settings, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return settings != nil && settings.ConfirmedAt != nil, nil
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code. Each
+// TOTP step and each recovery code can only be used once.
+func (s *TwoFactorService) Verify(ctx context.Context, userID int, code string) error {
+	settings, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if settings == nil || settings.ConfirmedAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, err := s.validateTOTP(settings.SecretEncrypted, code)
+		if err != nil {
+			return err
+		}
+
+		fresh, err := s.twoFactorRepo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+
+		if !fresh {
+			return ErrInvalidTwoFactorCode
+		}
+
+		return nil
+	}
+
+	consumed, err := s.twoFactorRepo.ConsumeRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *TwoFactorService) validateTOTP(secretEncrypted, code string) (int64, error) {
+	secret, err := s.box.Open(secretEncrypted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	step, ok := totp.Validate(string(secret), code, time.Now(), totpAllowedSkew)
+	if !ok {
+		return 0, ErrInvalidTwoFactorCode
+	}
+
+	return step, nil
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+
+		encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		hashes[i] = hashToken(encoded)
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
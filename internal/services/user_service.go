@@ -2,24 +2,41 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"strings"
+	"time"
+	"user-service/internal/config"
 	"user-service/internal/domain"
 	"user-service/internal/dto"
+	"user-service/internal/proofstore"
 	"user-service/internal/repository"
 )
 
+const referralBonus = 100
+
+var ErrTaskAlreadySubmitted = errors.New("task has already been submitted")
+
 type UserService struct {
-	userRepo repository.UserRepository
-	taskRepo repository.TaskRepository
+	userRepo      repository.UserRepository
+	taskRepo      repository.TaskRepository
+	emailService  *EmailVerificationService
+	proofs        proofstore.Store
+	maxProofBytes int64
 }
 
 func NewUserService(
+	cfg config.TaskProofConfig,
 	userRepo repository.UserRepository,
 	taskRepo repository.TaskRepository,
+	emailService *EmailVerificationService,
+	proofs proofstore.Store,
 ) *UserService {
 	return &UserService{
-		userRepo: userRepo,
-		taskRepo: taskRepo,
+		userRepo:      userRepo,
+		taskRepo:      taskRepo,
+		emailService:  emailService,
+		proofs:        proofs,
+		maxProofBytes: cfg.MaxBytes,
 	}
 }
 
@@ -34,7 +51,7 @@ func (s *UserService) GetUserInfoByID(ctx context.Context, userID int) (*dto.Use
 }
 
 func (s *UserService) GetLeaderBoard(ctx context.Context, limit int) (*[]dto.LeaderboardUserDTO, error) {
-	users, err := s.userRepo.GetTopUsersByBalance(ctx, limit)
+	users, err := s.userRepo.GetTopUsersByBalance(ctx, limit, s.emailService.Restricts(RestrictLeaderboard))
 	if err != nil {
 		return nil, err
 	}
@@ -47,54 +64,153 @@ func (s *UserService) GetLeaderBoard(ctx context.Context, limit int) (*[]dto.Lea
 	return &userDTOs, nil
 }
 
-func (s *UserService) CompleteTask(ctx context.Context, userID, taskID int) error {
-	task, err := s.taskRepo.GetTaskByID(ctx, taskID)
+// CompleteTask submits a task. Tasks in the auto verification mode are
+// approved and credited at once; the others wait for a moderator, and tasks
+// in the proof mode need a text, a link or a file. A rejected task can be
+// submitted again, the new proof replaces the old one.
+func (s *UserService) CompleteTask(ctx context.Context, userID int, req dto.CompleteTaskRequest, file *ProofFile) (*dto.TaskSubmissionResponse, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("task is not found: %w", err)
+		return nil, err
 	}
 
-	userTask := &domain.UserTask{
-		UserID: userID,
-		TaskID: taskID,
+	if err := s.emailService.Check(user, RestrictCompleteTasks); err != nil {
+		return nil, err
 	}
 
-	if err := s.taskRepo.CompleteTask(ctx, userTask); err != nil {
-		return err
+	task, err := s.taskRepo.GetTaskByID(ctx, req.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task == nil || task.Archived() {
+		return nil, ErrTaskNotFound
 	}
 
+	// Guests are free to create, so they must not fill the review queue and
+	// the proof store.
+	if user.IsGuest && task.VerificationMode != domain.TaskVerificationAuto {
+		return nil, ErrGuestNotAllowed
+	}
+
+	previous, err := s.taskRepo.FindSubmission(ctx, userID, task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil && previous.Status != domain.SubmissionRejected {
+		return nil, ErrTaskAlreadySubmitted
+	}
+
+	now := time.Now().UTC()
+	submission := &domain.UserTask{
+		UserID:      userID,
+		TaskID:      task.ID,
+		Status:      domain.SubmissionPending,
+		SubmittedAt: now,
+	}
+
+	if task.VerificationMode == domain.TaskVerificationAuto {
+		submission.Status = domain.SubmissionApproved
+		submission.CompletedAt = &now
+	} else {
+		submission.ProofText = strings.TrimSpace(req.ProofText)
+		submission.ProofURL = strings.TrimSpace(req.ProofURL)
+
+		if task.VerificationMode == domain.TaskVerificationProof && submission.ProofText == "" && submission.ProofURL == "" && file == nil {
+			return nil, ErrProofRequired
+		}
+
+		if file != nil {
+			if submission.ProofFileKey, submission.ProofContentType, err = saveProof(ctx, s.proofs, s.maxProofBytes, file); err != nil {
+				return nil, err
+			}
+			submission.ProofFileName = proofFileName(file.Name)
+		}
+	}
+
+	var submitted bool
+	if submission.Status == domain.SubmissionApproved {
+		submitted, err = s.taskRepo.SubmitAndCredit(ctx, submission, &domain.BalanceTransaction{
+			UserID: &userID,
+			Amount: task.Points,
+			Reason: domain.BalanceReasonTaskCompleted,
+			TaskID: &task.ID,
+		})
+	} else {
+		submitted, err = s.taskRepo.SubmitTask(ctx, submission)
+	}
+
+	if err == nil && !submitted {
+		err = ErrTaskAlreadySubmitted
+	}
+
+	if err != nil {
+		if submission.HasProofFile() {
+			deleteProof(ctx, s.proofs, submission.ProofFileKey)
+		}
+		return nil, err
+	}
+
+	if previous != nil && previous.HasProofFile() {
+		deleteProof(ctx, s.proofs, previous.ProofFileKey)
+	}
+
+	submission.Task = *task
+
+	response := dto.ToTaskSubmissionResponse(submission)
+	return &response, nil
+}
+
+func (s *UserService) AddReferrer(ctx context.Context, userID, referrerID int) error {
 	user, err := s.userRepo.GetUserById(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	newBalance := user.Balance + task.Points
-	if err := s.userRepo.UpdateBalance(ctx, userID, newBalance); err != nil {
+	if err := s.emailService.Check(user, RestrictSetReferrer); err != nil {
 		return err
 	}
 
-	return nil
+	// Guests are free to create, so they must not earn referral bonuses.
+	if user.IsGuest {
+		return ErrGuestNotAllowed
+	}
+
+	return s.userRepo.AddReferrerAndCredit(ctx, userID, referrerID, &domain.BalanceTransaction{
+		UserID:        &referrerID,
+		Amount:        referralBonus,
+		Reason:        domain.BalanceReasonReferralBonus,
+		RelatedUserID: &userID,
+	})
 }
 
-func (s *UserService) AddReferrer(ctx context.Context, userID, referrerID int) error {
-	if err := s.userRepo.AddReferrer(ctx, userID, referrerID); err != nil {
-		return err
+// GetUserCompletedTasks returns the submissions of the user with their
+// review status, most recently submitted first, a page at a time.
+func (s *UserService) GetUserCompletedTasks(ctx context.Context, userID int, query dto.PageQuery) (*dto.CompletedTasksResponse, error) {
+	beforeAt, beforeID, err := decodeTimeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
-	const referalBonus = 100
+	limit := pageSize(query.Limit)
 
-	referrer, err := s.userRepo.GetUserById(ctx, referrerID)
+	completions, err := s.taskRepo.GetUserCompletedTasks(ctx, userID, beforeAt, beforeID, limit+1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	newBalance := referrer.Balance + referalBonus
-	if err := s.userRepo.UpdateBalance(ctx, referrerID, newBalance); err != nil {
-		return err
+	response := &dto.CompletedTasksResponse{Tasks: make([]dto.CompletedTaskResponse, 0, len(completions))}
+
+	if len(completions) > limit {
+		completions = completions[:limit]
+		last := completions[len(completions)-1]
+		response.NextCursor = encodeTimeCursor(last.SubmittedAt, last.ID)
 	}
 
-	return nil
-}
+	for i := range completions {
+		response.Tasks = append(response.Tasks, dto.ToCompletedTaskResponse(&completions[i]))
+	}
 
-func (s *UserService) GetUserCompletedTasks(ctx context.Context, userID int) ([]domain.Task, error) {
-	return s.taskRepo.GetUserCompletedTasks(ctx, userID)
+	return response, nil
 }
@@ -0,0 +1,88 @@
+// Package username brings user supplied names to their canonical form and
+// checks them against the naming rules.
+package username
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 32
+)
+
+var (
+	ErrInvalidLength     = errors.New("username must be between 3 and 32 characters long")
+	ErrInvalidCharacters = errors.New("username may only contain latin letters, digits, '.', '_' and '-', and must start and end with a letter or digit")
+	ErrReserved          = errors.New("username is reserved")
+)
+
+var allowed = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9._-]*[a-z0-9])?$`)
+
+// reserved holds names that could be mistaken for the service itself or its
+// staff, plus path segments that would be confusing in profile URLs.
+var reserved = map[string]struct{}{
+	"admin":         {},
+	"administrator": {},
+	"api":           {},
+	"guest":         {},
+	"help":          {},
+	"login":         {},
+	"logout":        {},
+	"me":            {},
+	"moderator":     {},
+	"null":          {},
+	"official":      {},
+	"register":      {},
+	"root":          {},
+	"security":      {},
+	"staff":         {},
+	"support":       {},
+	"system":        {},
+	"undefined":     {},
+	"user-service":  {},
+}
+
+// Canonical returns the form names are compared in: NFKC normalized, so that
+// compatibility variants such as fullwidth letters collapse to plain ones,
+// and lower case. It does not validate the name and is what lookups use, so
+// accounts created before the rules existed can still sign in.
+func Canonical(raw string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(raw)))
+}
+
+// Normalize returns the canonical form of a new username, or an error when it
+// breaks the naming rules.
+func Normalize(raw string) (string, error) {
+	name := Canonical(raw)
+
+	if len(name) < MinLength || len(name) > MaxLength {
+		return "", ErrInvalidLength
+	}
+
+	if !allowed.MatchString(name) {
+		return "", ErrInvalidCharacters
+	}
+
+	if IsReserved(name) {
+		return "", ErrReserved
+	}
+
+	return name, nil
+}
+
+// IsReserved reports whether the name may not be registered. Separators are
+// ignored, so "ad.min" and "ad-min" are reserved too.
+func IsReserved(name string) bool {
+	name = Canonical(name)
+	if _, ok := reserved[name]; ok {
+		return true
+	}
+
+	_, ok := reserved[strings.NewReplacer(".", "", "_", "", "-", "").Replace(name)]
+	return ok
+}
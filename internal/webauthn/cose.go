@@ -0,0 +1,136 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers of the signatures we verify.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms is offered to authenticators in order of preference.
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+const (
+	coseKeyType      = 1
+	coseKeyAlgorithm = 3
+	coseKeyCurve     = -1
+	coseKeyX         = -2
+	coseKeyY         = -3
+	coseKeyRSAN      = -1
+	coseKeyRSAE      = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+
+	minRSAKeyBits = 2048
+)
+
+var ErrUnsupportedKey = errors.New("webauthn: unsupported public key")
+
+// PublicKey is a credential public key decoded from its COSE form.
+type PublicKey struct {
+	Algorithm int
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as stored for a credential.
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	item, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("webauthn: trailing data after public key")
+	}
+
+	fields, ok := item.(map[any]any)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	return publicKeyFromCOSE(fields)
+}
+
+func publicKeyFromCOSE(fields map[any]any) (*PublicKey, error) {
+	kty, _ := fields[int64(coseKeyType)].(int64)
+	alg, _ := fields[int64(coseKeyAlgorithm)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := fields[int64(coseKeyCurve)].(int64)
+		x, _ := fields[int64(coseKeyX)].([]byte)
+		y, _ := fields[int64(coseKeyY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+
+		point := append(append([]byte{4}, x...), y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		return &PublicKey{Algorithm: AlgES256, key: key}, nil
+
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := fields[int64(coseKeyCurve)].(int64)
+		x, _ := fields[int64(coseKeyX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Algorithm: AlgEdDSA, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := fields[int64(coseKeyRSAN)].([]byte)
+		e, _ := fields[int64(coseKeyRSAE)].([]byte)
+		if len(n)*8 < minRSAKeyBits || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &PublicKey{
+			Algorithm: AlgRS256,
+			key:       &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())},
+		}, nil
+
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// Verify checks a signature made by the credential over message.
+func (k *PublicKey) Verify(message, signature []byte) error {
+	var ok bool
+
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, message, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !ok {
+		return verificationError("signature does not match the credential")
+	}
+
+	return nil
+}
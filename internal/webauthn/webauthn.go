@@ -0,0 +1,531 @@
+// Package webauthn implements the relying party side of the WebAuthn Level 2
+// registration and authentication ceremonies for passkeys. Options and
+// responses use the JSON serialization of the browser API, with binary fields
+// as unpadded base64url. Attestation is not evaluated against any trust
+// store: "none" and "packed" statements are checked for consistency only.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+
+	credentialType = "public-key"
+	challengeSize  = 32
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagBackupElig   = 0x08
+	flagBackedUp     = 0x10
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+var (
+	ErrVerificationFailed = errors.New("webauthn: verification failed")
+	// ErrSignCountRegressed comes wrapped in ErrVerificationFailed when the
+	// sign counter did not grow, a sign that the credential was cloned.
+	ErrSignCountRegressed = errors.New("sign counter did not increase")
+)
+
+func verificationError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrVerificationFailed, fmt.Sprintf(format, args...))
+}
+
+// Bytes is binary data that travels as unpadded base64url in JSON.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return fmt.Errorf("webauthn: invalid base64url: %w", err)
+	}
+
+	*b = decoded
+	return nil
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions is passed to navigator.credentials.create as publicKey.
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is passed to navigator.credentials.get as publicKey.
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type AttestationResponse struct {
+	ClientDataJSON    Bytes    `json:"clientDataJSON"`
+	AttestationObject Bytes    `json:"attestationObject"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// RegistrationCredential is the PublicKeyCredential returned by
+// navigator.credentials.create.
+type RegistrationCredential struct {
+	ID       string              `json:"id"`
+	RawID    Bytes               `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+type AssertionResponse struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON"`
+	AuthenticatorData Bytes `json:"authenticatorData"`
+	Signature         Bytes `json:"signature"`
+	UserHandle        Bytes `json:"userHandle,omitempty"`
+}
+
+// AssertionCredential is the PublicKeyCredential returned by
+// navigator.credentials.get.
+type AssertionCredential struct {
+	ID       string            `json:"id"`
+	RawID    Bytes             `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+// Credential is what a successful registration leaves to be stored.
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	Algorithm      int
+	SignCount      uint32
+	AAGUID         []byte
+	BackupEligible bool
+	BackedUp       bool
+	Transports     []string
+}
+
+// Assertion is the outcome of a successful authentication.
+type Assertion struct {
+	SignCount uint32
+	BackedUp  bool
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// RelyingParty holds the identity of this service towards authenticators.
+// Passkeys are scoped to ID, a registrable domain, and responses are only
+// accepted from the listed origins.
+type RelyingParty struct {
+	id      string
+	name    string
+	origins []string
+	timeout time.Duration
+}
+
+func NewRelyingParty(id, name string, origins []string, timeout time.Duration) *RelyingParty {
+	return &RelyingParty{
+		id:      id,
+		name:    name,
+		origins: origins,
+		timeout: timeout,
+	}
+}
+
+func (rp *RelyingParty) Timeout() time.Duration {
+	return rp.timeout
+}
+
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate WebAuthn challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// CreationOptions asks for a discoverable, user-verified credential so that
+// it can later sign in without a username and counts as a second factor by
+// itself.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: credentialType, Alg: alg})
+	}
+
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return CreationOptions{
+		Challenge:          challenge,
+		RP:                 RelyingPartyEntity{ID: rp.id, Name: rp.name},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            rp.timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions starts a username-less sign in: the authenticator offers
+// every passkey it holds for this relying party.
+func (rp *RelyingParty) RequestOptions(challenge []byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.timeout.Milliseconds(),
+		RPID:             rp.id,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: "required",
+	}
+}
+
+// Descriptor refers to a stored credential in options.
+func Descriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: credentialType, ID: id, Transports: transports}
+}
+
+// ClientChallenge extracts the challenge the client signed, so the caller
+// can look up the ceremony it belongs to before verifying the response.
+func ClientChallenge(clientDataJSON []byte) ([]byte, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, verificationError("malformed client data")
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil {
+		return nil, verificationError("malformed challenge")
+	}
+
+	return challenge, nil
+}
+
+// VerifyRegistration checks a response to CreationOptions issued with
+// challenge and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(credential RegistrationCredential, challenge []byte) (*Credential, error) {
+	if credential.Type != credentialType {
+		return nil, verificationError("unexpected credential type %q", credential.Type)
+	}
+
+	if err := rp.verifyClientData(credential.Response.ClientDataJSON, CeremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	item, rest, err := decodeCBOR(credential.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, verificationError("malformed attestation object")
+	}
+
+	attestation, ok := item.(map[any]any)
+	if !ok {
+		return nil, verificationError("malformed attestation object")
+	}
+
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[any]any)
+	rawAuthData, _ := attestation["authData"].([]byte)
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.flags&flagAttested == 0 {
+		return nil, verificationError("no attested credential data")
+	}
+
+	if !bytes.Equal(authData.credentialID, credential.RawID) {
+		return nil, verificationError("credential id does not match")
+	}
+
+	publicKey, err := ParsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, verificationError("%v", err)
+	}
+
+	clientDataHash := sha256.Sum256(credential.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+
+	if err := verifyAttestationStatement(format, statement, signed, publicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		Algorithm:      publicKey.Algorithm,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		BackupEligible: authData.flags&flagBackupElig != 0,
+		BackedUp:       authData.flags&flagBackedUp != 0,
+		Transports:     credential.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion checks a response to RequestOptions issued with challenge
+// against the stored public key and sign counter. A counter that does not
+// grow means the credential may have been cloned and is rejected; passkeys
+// that do not count at all report zero every time.
+func (rp *RelyingParty) VerifyAssertion(credential AssertionCredential, challenge, storedKey []byte, storedCount uint32) (*Assertion, error) {
+	if credential.Type != credentialType {
+		return nil, verificationError("unexpected credential type %q", credential.Type)
+	}
+
+	if err := rp.verifyClientData(credential.Response.ClientDataJSON, CeremonyGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ParsePublicKey(storedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(credential.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), credential.Response.AuthenticatorData...), clientDataHash[:]...)
+
+	if err := publicKey.Verify(signed, credential.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	if !CounterAdvanced(storedCount, authData.signCount) {
+		return nil, fmt.Errorf("%w: %w", ErrVerificationFailed, ErrSignCountRegressed)
+	}
+
+	return &Assertion{
+		SignCount: authData.signCount,
+		BackedUp:  authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// CounterAdvanced reports whether received is a valid successor of stored.
+func CounterAdvanced(stored, received uint32) bool {
+	if stored == 0 && received == 0 {
+		return true
+	}
+	return received > stored
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return verificationError("malformed client data")
+	}
+
+	if data.Type != ceremony {
+		return verificationError("unexpected ceremony %q", data.Type)
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return verificationError("challenge does not match")
+	}
+
+	if !slices.Contains(rp.origins, data.Origin) {
+		return verificationError("origin %q is not allowed", data.Origin)
+	}
+
+	if data.CrossOrigin {
+		return verificationError("cross-origin requests are not allowed")
+	}
+
+	return nil
+}
+
+func (rp *RelyingParty) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, verificationError("authenticator data too short")
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.id))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return nil, verificationError("credential belongs to another relying party")
+	}
+
+	if authData.flags&flagUserPresent == 0 {
+		return nil, verificationError("user was not present")
+	}
+
+	if authData.flags&flagUserVerified == 0 {
+		return nil, verificationError("user was not verified")
+	}
+
+	rest := data[37:]
+
+	if authData.flags&flagAttested != 0 {
+		if len(rest) < 18 {
+			return nil, verificationError("attested credential data too short")
+		}
+
+		authData.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, verificationError("invalid credential id length")
+		}
+
+		authData.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, verificationError("malformed credential public key")
+		}
+
+		authData.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if authData.flags&flagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, verificationError("malformed extensions")
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, verificationError("trailing authenticator data")
+	}
+
+	return authData, nil
+}
+
+func verifyAttestationStatement(format string, statement map[any]any, signed []byte, credentialKey *PublicKey) error {
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return verificationError("none attestation with a statement")
+		}
+		return nil
+
+	case "packed":
+		alg, _ := statement["alg"].(int64)
+		signature, _ := statement["sig"].([]byte)
+		chain, hasChain := statement["x5c"].([]any)
+
+		if !hasChain {
+			if int(alg) != credentialKey.Algorithm {
+				return verificationError("self attestation algorithm does not match the credential")
+			}
+			return credentialKey.Verify(signed, signature)
+		}
+
+		if len(chain) == 0 {
+			return verificationError("empty attestation certificate chain")
+		}
+
+		der, _ := chain[0].([]byte)
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return verificationError("malformed attestation certificate")
+		}
+
+		var signatureAlgorithm x509.SignatureAlgorithm
+		switch alg {
+		case AlgES256:
+			signatureAlgorithm = x509.ECDSAWithSHA256
+		case AlgEdDSA:
+			signatureAlgorithm = x509.PureEd25519
+		case AlgRS256:
+			signatureAlgorithm = x509.SHA256WithRSA
+		default:
+			return verificationError("unsupported attestation algorithm %d", alg)
+		}
+
+		if err := certificate.CheckSignature(signatureAlgorithm, signed, signature); err != nil {
+			return verificationError("attestation signature does not match")
+		}
+		return nil
+
+	default:
+		return verificationError("unsupported attestation format %q", format)
+	}
+}
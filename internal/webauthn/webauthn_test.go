@@ -0,0 +1,167 @@
+package webauthn_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"user-service/internal/webauthn"
+	"user-service/internal/webauthn/webauthntest"
+)
+
+const (
+	rpID   = "localhost"
+	origin = "http://localhost:8080"
+)
+
+func newRelyingParty() *webauthn.RelyingParty {
+	return webauthn.NewRelyingParty(rpID, "User Service", []string{origin}, time.Minute)
+}
+
+func challenge(t *testing.T) []byte {
+	t.Helper()
+
+	c, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func register(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	t.Helper()
+
+	c := challenge(t)
+	created, err := authenticator.Create(rp.CreationOptions(c, webauthn.UserEntity{ID: []byte("1"), Name: "alice", DisplayName: "alice"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := rp.VerifyRegistration(*created, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credential
+}
+
+func assert(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator, credential *webauthn.Credential, storedCount uint32) (*webauthn.Assertion, error) {
+	t.Helper()
+
+	c := challenge(t)
+	answer, err := authenticator.Get(rp.RequestOptions(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rp.VerifyAssertion(*answer, c, credential.PublicKey, storedCount)
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := newRelyingParty()
+	authenticator := webauthntest.NewAuthenticator(origin)
+	credential := register(t, rp, authenticator)
+
+	if credential.Algorithm != webauthn.AlgES256 || credential.SignCount != 1 {
+		t.Fatalf("unexpected credential: %+v", credential)
+	}
+
+	assertion, err := assert(t, rp, authenticator, credential, credential.SignCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assertion.SignCount != 2 {
+		t.Fatalf("got sign count %d, want 2", assertion.SignCount)
+	}
+}
+
+func TestAssertionRejectsRegressedSignCount(t *testing.T) {
+	rp := newRelyingParty()
+	authenticator := webauthntest.NewAuthenticator(origin)
+	credential := register(t, rp, authenticator)
+
+	// A clone that kept signing elsewhere: the server has seen 5, this copy
+	// answers with 2.
+	_, err := assert(t, rp, authenticator, credential, 5)
+	if !errors.Is(err, webauthn.ErrSignCountRegressed) {
+		t.Fatalf("got %v, want ErrSignCountRegressed", err)
+	}
+
+	// An equal counter is no increase either.
+	if err := authenticator.SetSignCount(rpID, credential.ID, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := assert(t, rp, authenticator, credential, 5); !errors.Is(err, webauthn.ErrSignCountRegressed) {
+		t.Fatalf("got %v, want ErrSignCountRegressed", err)
+	}
+}
+
+func TestAssertionAcceptsNonCountingAuthenticator(t *testing.T) {
+	rp := newRelyingParty()
+	authenticator := webauthntest.NewAuthenticator(origin)
+	authenticator.Counting = false
+	credential := register(t, rp, authenticator)
+
+	if credential.SignCount != 0 {
+		t.Fatalf("got sign count %d, want 0", credential.SignCount)
+	}
+
+	for i := 0; i < 2; i++ {
+		assertion, err := assert(t, rp, authenticator, credential, 0)
+		if err != nil {
+			t.Fatalf("assertion %d: %v", i+1, err)
+		}
+		if assertion.SignCount != 0 {
+			t.Fatalf("got sign count %d, want 0", assertion.SignCount)
+		}
+	}
+}
+
+func TestVerificationRejectsWrongChallenge(t *testing.T) {
+	rp := newRelyingParty()
+	authenticator := webauthntest.NewAuthenticator(origin)
+
+	created, err := authenticator.Create(rp.CreationOptions(challenge(t), webauthn.UserEntity{ID: []byte("1"), Name: "alice"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyRegistration(*created, challenge(t)); !errors.Is(err, webauthn.ErrVerificationFailed) {
+		t.Fatalf("registration: got %v, want ErrVerificationFailed", err)
+	}
+
+	credential := register(t, rp, authenticator)
+	answer, err := authenticator.Get(rp.RequestOptions(challenge(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(*answer, challenge(t), credential.PublicKey, credential.SignCount); !errors.Is(err, webauthn.ErrVerificationFailed) {
+		t.Fatalf("assertion: got %v, want ErrVerificationFailed", err)
+	}
+}
+
+func TestVerificationRejectsWrongOrigin(t *testing.T) {
+	rp := newRelyingParty()
+	credential := register(t, rp, webauthntest.NewAuthenticator(origin))
+
+	phishing := webauthntest.NewAuthenticator("https://login.example.net")
+
+	c := challenge(t)
+	created, err := phishing.Create(rp.CreationOptions(c, webauthn.UserEntity{ID: []byte("1"), Name: "alice"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyRegistration(*created, c); !errors.Is(err, webauthn.ErrVerificationFailed) || !strings.Contains(err.Error(), "origin") {
+		t.Fatalf("registration: got %v, want a rejected origin", err)
+	}
+
+	// The client data is checked before the signature, so the origin is
+	// what fails here.
+	c = challenge(t)
+	answer, err := phishing.Get(rp.RequestOptions(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(*answer, c, credential.PublicKey, credential.SignCount); !errors.Is(err, webauthn.ErrVerificationFailed) || !strings.Contains(err.Error(), "origin") {
+		t.Fatalf("assertion: got %v, want a rejected origin", err)
+	}
+}
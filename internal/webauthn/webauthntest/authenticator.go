@@ -0,0 +1,221 @@
+// Package webauthntest is a software authenticator for running the passkey
+// ceremonies offline, in tests or from scripts. It holds ES256 discoverable
+// credentials in memory, always reports the user as present and verified and
+// answers with "none" attestation, like a browser does for most passkeys.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"user-service/internal/webauthn"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var ErrNoCredential = errors.New("webauthntest: no matching credential")
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// Authenticator acts as both the browser and the authenticator for origin.
+type Authenticator struct {
+	origin string
+
+	mu          sync.Mutex
+	credentials []*credential
+	// Counting makes the authenticator increase its sign counter with every
+	// signature; without it the counter stays at zero as with most passkeys.
+	Counting bool
+}
+
+func NewAuthenticator(origin string) *Authenticator {
+	return &Authenticator{origin: origin, Counting: true}
+}
+
+// Create answers navigator.credentials.create.
+func (a *Authenticator) Create(options webauthn.CreationOptions) (*webauthn.RegistrationCredential, error) {
+	if !slices.ContainsFunc(options.PubKeyCredParams, func(p webauthn.CredentialParameter) bool {
+		return p.Alg == webauthn.AlgES256
+	}) {
+		return nil, errors.New("webauthntest: ES256 is not offered")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(options.RP.ID, excluded.ID) != nil {
+			return nil, errors.New("webauthntest: credential already registered")
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	cred := &credential{
+		id:         id,
+		rpID:       options.RP.ID,
+		userHandle: options.User.ID,
+		key:        key,
+	}
+
+	// A new passkey replaces the one the same user already had here.
+	a.credentials = slices.DeleteFunc(a.credentials, func(c *credential) bool {
+		return c.rpID == cred.rpID && string(c.userHandle) == string(cred.userHandle)
+	})
+	a.credentials = append(a.credentials, cred)
+
+	clientDataJSON, err := a.clientData(webauthn.CeremonyCreate, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(cred, flagUserPresent|flagUserVerified|flagAttested)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(cred.id)))
+	authData = append(authData, cred.id...)
+	authData = append(authData, encodeCOSEKey(&key.PublicKey)...)
+
+	attestationObject := encodeMap([]pair{
+		{key: encodeText("fmt"), value: encodeText("none")},
+		{key: encodeText("attStmt"), value: encodeMap(nil)},
+		{key: encodeText("authData"), value: encodeBytes(authData)},
+	})
+
+	return &webauthn.RegistrationCredential{
+		ID:    base64.RawURLEncoding.EncodeToString(cred.id),
+		RawID: cred.id,
+		Type:  "public-key",
+		Response: webauthn.AttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Get answers navigator.credentials.get with the first matching credential.
+func (a *Authenticator) Get(options webauthn.RequestOptions) (*webauthn.AssertionCredential, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var cred *credential
+	if len(options.AllowCredentials) == 0 {
+		for _, c := range a.credentials {
+			if c.rpID == options.RPID {
+				cred = c
+				break
+			}
+		}
+	} else {
+		for _, allowed := range options.AllowCredentials {
+			if cred = a.find(options.RPID, allowed.ID); cred != nil {
+				break
+			}
+		}
+	}
+
+	if cred == nil {
+		return nil, ErrNoCredential
+	}
+
+	clientDataJSON, err := a.clientData(webauthn.CeremonyGet, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(cred, flagUserPresent|flagUserVerified)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionCredential{
+		ID:    base64.RawURLEncoding.EncodeToString(cred.id),
+		RawID: cred.id,
+		Type:  "public-key",
+		Response: webauthn.AssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        cred.userHandle,
+		},
+	}, nil
+}
+
+// SetSignCount rewinds or advances the counter of a credential, for example
+// to act as a cloned authenticator.
+func (a *Authenticator) SetSignCount(rpID string, id []byte, count uint32) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cred := a.find(rpID, id)
+	if cred == nil {
+		return ErrNoCredential
+	}
+
+	cred.signCount = count
+	return nil
+}
+
+func (a *Authenticator) find(rpID string, id []byte) *credential {
+	for _, c := range a.credentials {
+		if c.rpID == rpID && string(c.id) == string(id) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	data, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webauthntest: %w", err)
+	}
+	return data, nil
+}
+
+func (a *Authenticator) authenticatorData(cred *credential, flags byte) []byte {
+	if a.Counting {
+		cred.signCount++
+	}
+
+	rpIDHash := sha256.Sum256([]byte(cred.rpID))
+
+	data := append([]byte(nil), rpIDHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, cred.signCount)
+}
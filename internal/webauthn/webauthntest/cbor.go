@@ -0,0 +1,67 @@
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+)
+
+// Just enough CBOR encoding to build attestation objects and COSE keys.
+
+type pair struct {
+	key   []byte
+	value []byte
+}
+
+func encodeHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+	}
+}
+
+func encodeInt(n int64) []byte {
+	if n < 0 {
+		return encodeHead(1, uint64(-1-n))
+	}
+	return encodeHead(0, uint64(n))
+}
+
+func encodeBytes(b []byte) []byte {
+	return append(encodeHead(2, uint64(len(b))), b...)
+}
+
+func encodeText(s string) []byte {
+	return append(encodeHead(3, uint64(len(s))), s...)
+}
+
+func encodeMap(pairs []pair) []byte {
+	out := encodeHead(5, uint64(len(pairs)))
+	for _, p := range pairs {
+		out = append(out, p.key...)
+		out = append(out, p.value...)
+	}
+	return out
+}
+
+func encodeCOSEKey(key *ecdsa.PublicKey) []byte {
+	point, err := key.Bytes()
+	if err != nil {
+		panic(err)
+	}
+
+	return encodeMap([]pair{
+		{key: encodeInt(1), value: encodeInt(2)},
+		{key: encodeInt(3), value: encodeInt(-7)},
+		{key: encodeInt(-1), value: encodeInt(1)},
+		{key: encodeInt(-2), value: encodeBytes(point[1:33])},
+		{key: encodeInt(-3), value: encodeBytes(point[33:])},
+	})
+}